@@ -15,6 +15,8 @@ import (
 var (
 	addrTCP = flag.String("addr-tcp", ":601", "Listen address <ip>:<port>")
 	addrUDP = flag.String("addr-udp", ":514", "Listen address <ip>:<port>")
+
+	parserConfig = flag.String("parser-config", "", "Path to a JSON file with the parser settings of each listener")
 )
 
 func main() {
@@ -33,6 +35,13 @@ func run(ctx context.Context, _ *zap.Logger, client *axiom.Client) error {
 		AddrTCP: *addrTCP,
 	}
 
+	if *parserConfig != "" {
+		var err error
+		if config.Parser, err = server.ReadParserConfig(*parserConfig); err != nil {
+			return cmd.Error("read parser config", err)
+		}
+	}
+
 	srv, err := server.NewServer(client, config)
 	if err != nil {
 		return cmd.Error("create server", err)
@@ -0,0 +1,188 @@
+package parser
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// accessLogFieldPrefix namespaces the access log fields in Log.Metadata.
+const accessLogFieldPrefix = "http."
+
+const (
+	// commonLogFormat is the Common Log Format, in the syntax of nginx's
+	// log_format.
+	commonLogFormat = `$remote_addr - $remote_user [$time_local] "$request" $status $body_bytes_sent`
+	// combinedLogFormat is nginx's default format, which equals Apache's
+	// "combined".
+	combinedLogFormat = commonLogFormat + ` "$http_referer" "$http_user_agent"`
+)
+
+var (
+	// accessLogApplications send access logs in one of the default formats.
+	accessLogApplications = map[string]bool{"nginx": true, "httpd": true, "apache2": true, "apache": true}
+
+	defaultAccessLogFormats = []*accessLogFormat{
+		mustCompileAccessLogFormat(combinedLogFormat),
+		mustCompileAccessLogFormat(commonLogFormat),
+	}
+
+	accessLogVariableRegex = regexp.MustCompile(`\$(?:\{(\w+)\}|(\w+))`)
+
+	// accessLogFieldNames renames the well-known variables, everything else
+	// keeps the name of its variable.
+	accessLogFieldNames = map[string]string{
+		"remote_addr":     "clientIp",
+		"remote_user":     "user",
+		"time_local":      "time",
+		"time_iso8601":    "time",
+		"request_method":  "method",
+		"request_uri":     "path",
+		"server_protocol": "protocol",
+		"body_bytes_sent": "bytes",
+		"http_referer":    "referer",
+		"http_user_agent": "userAgent",
+		"request_time":    "requestTime",
+	}
+
+	accessLogIntVariables = map[string]bool{
+		"status": true, "body_bytes_sent": true, "bytes_sent": true, "request_length": true,
+		"connection": true, "connection_requests": true, "remote_port": true, "server_port": true,
+	}
+	accessLogFloatVariables = map[string]bool{
+		"request_time": true, "upstream_connect_time": true, "upstream_header_time": true,
+		"upstream_response_time": true, "msec": true,
+	}
+)
+
+// accessLogFormat is a compiled nginx log_format.
+type accessLogFormat struct {
+	regex     *regexp.Regexp
+	variables []string
+}
+
+// compileAccessLogFormat turns an nginx log_format like
+//
+//	$remote_addr - $remote_user [$time_local] "$request" $status
+//
+// into a regular expression, where every variable matches anything up to the
+// text following it.
+func compileAccessLogFormat(format string) (*accessLogFormat, error) {
+	var (
+		pattern   strings.Builder
+		variables []string
+		last      int
+	)
+
+	pattern.WriteByte('^')
+	for _, loc := range accessLogVariableRegex.FindAllStringSubmatchIndex(format, -1) {
+		pattern.WriteString(regexp.QuoteMeta(format[last:loc[0]]))
+
+		// either `${name}` or `$name`
+		if loc[2] >= 0 {
+			variables = append(variables, format[loc[2]:loc[3]])
+		} else {
+			variables = append(variables, format[loc[4]:loc[5]])
+		}
+
+		if loc[1] == len(format) {
+			pattern.WriteString("(.*)")
+		} else {
+			pattern.WriteString("(.*?)")
+		}
+		last = loc[1]
+	}
+	pattern.WriteString(regexp.QuoteMeta(format[last:]))
+	pattern.WriteByte('$')
+
+	if len(variables) == 0 {
+		return nil, errors.New("format has no variables")
+	}
+
+	regex, err := regexp.Compile(pattern.String())
+	if err != nil {
+		return nil, err
+	}
+	return &accessLogFormat{regex: regex, variables: variables}, nil
+}
+
+func mustCompileAccessLogFormat(format string) *accessLogFormat {
+	f, err := compileAccessLogFormat(format)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+// parseAccessLog parses the access log line of a web server in msg.Text,
+// trying the format configured for the application before the Combined and
+// Common Log Formats. Only the applications with a configured format and
+// the well-known web servers are considered. Its timestamp only replaces the
+// one of the message if Config.AccessLogTimestamp is set.
+func parseAccessLog(msg *Log, config *Config) bool {
+	formats := defaultAccessLogFormats
+	if format, ok := config.accessLogFormats[msg.Application]; ok {
+		formats = append([]*accessLogFormat{format}, formats...)
+	} else if !accessLogApplications[msg.Application] {
+		return false
+	}
+
+	text := strings.TrimSpace(msg.Text)
+	for _, format := range formats {
+		if values := format.regex.FindStringSubmatch(text); values != nil {
+			setAccessLogFields(msg, format.variables, values[1:], config)
+			return true
+		}
+	}
+	return false
+}
+
+func setAccessLogFields(msg *Log, variables, values []string, config *Config) {
+	for i, variable := range variables {
+		value := values[i]
+		if value == "" || value == "-" {
+			continue
+		}
+
+		name, ok := accessLogFieldNames[variable]
+		if !ok {
+			name = variable
+		}
+		msg.Metadata[accessLogFieldPrefix+name] = accessLogValue(variable, value)
+
+		switch variable {
+		case "request":
+			// `GET /index.html HTTP/1.1`, anything else is kept as is
+			if parts := strings.Fields(value); len(parts) == 3 {
+				msg.Metadata[accessLogFieldPrefix+"method"] = parts[0]
+				msg.Metadata[accessLogFieldPrefix+"path"] = parts[1]
+				msg.Metadata[accessLogFieldPrefix+"protocol"] = parts[2]
+			}
+		case "time_local", "time_iso8601", "msec":
+			if !config.AccessLogTimestamp {
+				continue
+			}
+			if ts, zoneless, ok := parseTimestamp(value, nil); ok && !zoneless {
+				msg.Timestamp = ts.UnixNano()
+				msg.hasWallClock = false
+			}
+		}
+	}
+}
+
+// accessLogValue types the value of a variable, keeping it as a string if it
+// doesn't parse.
+func accessLogValue(variable, value string) any {
+	switch {
+	case accessLogIntVariables[variable]:
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return n
+		}
+	case accessLogFloatVariables[variable]:
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	}
+	return value
+}
@@ -0,0 +1,83 @@
+package parser
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAccessLog(t *testing.T) {
+	const combined = `<190>Jan 18 11:07:53 web01 nginx: 10.0.0.1 - alice [18/Jan/2024:11:07:52 +0100] "GET /index.html?a=b HTTP/1.1" 200 612 "https://example.com/" "Mozilla/5.0 (X11; Linux x86_64)"`
+
+	msg := ParseLineWithFallback([]byte(combined), "10.1.1.1")
+	require.NotNil(t, msg)
+	assert.Equal(t, "nginx", msg.Application)
+	assert.Equal(t, bsdDate(1, 18, 11, 7, 53, 0, time.Local).UnixNano(), msg.Timestamp)
+	assert.Equal(t, map[string]any{
+		"http.clientIp":  "10.0.0.1",
+		"http.user":      "alice",
+		"http.time":      "18/Jan/2024:11:07:52 +0100",
+		"http.request":   "GET /index.html?a=b HTTP/1.1",
+		"http.method":    "GET",
+		"http.path":      "/index.html?a=b",
+		"http.protocol":  "HTTP/1.1",
+		"http.status":    int64(200),
+		"http.bytes":     int64(612),
+		"http.referer":   "https://example.com/",
+		"http.userAgent": "Mozilla/5.0 (X11; Linux x86_64)",
+	}, msg.Metadata)
+
+	// the Common Log Format, from Apache
+	msg = ParseLineWithFallback([]byte(`<190>Jan 18 11:07:53 web01 httpd[42]: 10.0.0.2 - - [18/Jan/2024:11:07:52 +0100] "POST /login HTTP/2.0" 302 -`), "10.1.1.1")
+	require.NotNil(t, msg)
+	assert.Equal(t, map[string]any{
+		"http.clientIp": "10.0.0.2",
+		"http.time":     "18/Jan/2024:11:07:52 +0100",
+		"http.request":  "POST /login HTTP/2.0",
+		"http.method":   "POST",
+		"http.path":     "/login",
+		"http.protocol": "HTTP/2.0",
+		"http.status":   int64(302),
+	}, msg.Metadata)
+
+	// other applications are left alone
+	msg = ParseLineWithFallback([]byte(`<190>Jan 18 11:07:53 web01 app: 10.0.0.1 - - [18/Jan/2024:11:07:52 +0100] "GET / HTTP/1.1" 200 612`), "10.1.1.1")
+	require.NotNil(t, msg)
+	assert.NotContains(t, msg.Metadata, "http.status")
+}
+
+func TestParseAccessLogCustomFormat(t *testing.T) {
+	config := mustCompile(&Config{
+		AccessLogFormats: map[string]string{
+			"openresty": `$remote_addr [$time_iso8601] "$request_method ${request_uri}" $status $request_time $upstream_response_time "$http_x_forwarded_for"`,
+		},
+		AccessLogTimestamp: true,
+	})
+
+	raw := `<190>Jan 18 11:07:53 web01 openresty: 10.0.0.1 [2024-01-18T11:07:52+01:00] "GET /api/v1/items" 504 0.250 0.249, 0.001 "198.51.100.7"`
+	msg := parseLineWithFallback([]byte(raw), "10.1.1.1", config)
+	require.NotNil(t, msg)
+	assert.Equal(t, time.Date(2024, 1, 18, 10, 7, 52, 0, time.UTC).UnixNano(), msg.Timestamp)
+	assert.Equal(t, map[string]any{
+		"http.clientIp":               "10.0.0.1",
+		"http.time":                   "2024-01-18T11:07:52+01:00",
+		"http.method":                 "GET",
+		"http.path":                   "/api/v1/items",
+		"http.status":                 int64(504),
+		"http.requestTime":            0.25,
+		"http.upstream_response_time": "0.249, 0.001",
+		"http.http_x_forwarded_for":   "198.51.100.7",
+	}, msg.Metadata)
+
+	// the defaults still apply to the configured application
+	raw = `<190>Jan 18 11:07:53 web01 openresty: 10.0.0.1 - - [18/Jan/2024:11:07:52 +0100] "GET / HTTP/1.1" 200 612`
+	msg = parseLineWithFallback([]byte(raw), "10.1.1.1", config)
+	require.NotNil(t, msg)
+	assert.Equal(t, int64(200), msg.Metadata["http.status"])
+	assert.Equal(t, time.Date(2024, 1, 18, 10, 7, 52, 0, time.UTC).UnixNano(), msg.Timestamp)
+
+	_, err := NewWithConfig(func(*Log) {}, &Config{AccessLogFormats: map[string]string{"nginx": "no variables"}})
+	assert.Error(t, err)
+}
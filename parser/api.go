@@ -4,6 +4,7 @@ import (
 	fmt "fmt"
 	"maps"
 	"strings"
+	"time"
 )
 
 // Emergency...
@@ -88,18 +89,42 @@ type RawLogQuery struct {
 
 // Log ...
 type Log struct {
-	RemoteAddr  string
-	Severity    int64
-	Timestamp   int64
-	Hostname    string
-	Application string
-	Text        string
-	Metadata    map[string]any
+	RemoteAddr string
+	Severity   int64
+	Timestamp  int64
+	Hostname   string
+	// ResolvedHostname is the name RemoteAddr resolves to, if the parser is
+	// configured to resolve it.
+	ResolvedHostname string
+	Application      string
+	// ProcID is the process identifier as sent, either the RFC 5424 PROCID
+	// or the bracketed part of an RFC 3164 tag.
+	ProcID string
+	// PID is ProcID as a number, or 0 if ProcID is not a number that fits.
+	PID      int64
+	Text     string
+	Metadata map[string]any
+	// Raw is the line as it was received, if the parser is configured to
+	// keep it. Messages reassembled or joined from several lines keep all
+	// of them, separated by newlines.
+	Raw string
+
+	// wallClock is set when the timestamp was sent without an offset, so
+	// Timestamp depends on the time zone of the sender. Its year is 0 when
+	// the timestamp didn't include one either.
+	wallClock    time.Time
+	hasWallClock bool
+	// reassemble is set for the lines of container logs, which the runtime
+	// may have split. partial is set if more of the message follows.
+	reassemble bool
+	partial    bool
 }
 
 func (l *Log) Merge(other *Log) {
 	if other.Timestamp != 0 && other.Timestamp != l.Timestamp {
 		l.Timestamp = other.Timestamp
+		l.wallClock = other.wallClock
+		l.hasWallClock = other.hasWallClock
 	}
 	if other.Severity != 0 && other.Severity != l.Severity {
 		l.Severity = other.Severity
@@ -113,6 +138,10 @@ func (l *Log) Merge(other *Log) {
 	if other.Application != "" {
 		l.Application = other.Application
 	}
+	if other.ProcID != "" {
+		l.ProcID = other.ProcID
+		l.PID = other.PID
+	}
 	if other.Text != "" {
 		l.Text = other.Text
 	}
@@ -135,7 +164,8 @@ func (l *Log) PrettyPrint() {
 		Timestamp: %d
 		Hostname: %s
 		Application: %s
+		ProcID: %s
 		Text: %s
 		Metadata: %s
-`, l.RemoteAddr, l.Severity, l.Timestamp, l.Hostname, l.Application, l.Text, strings.Join(metadata, ","))
+`, l.RemoteAddr, l.Severity, l.Timestamp, l.Hostname, l.Application, l.ProcID, l.Text, strings.Join(metadata, ","))
 }
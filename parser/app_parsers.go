@@ -2,20 +2,61 @@ package parser
 
 import "maps"
 
-func parseApp(msg *Log) {
+// The built-in parsers, in the order they run. The configured Grok rules and
+// application specific formats come first, the self-describing payloads any
+// application may send last.
+func init() {
+	for _, p := range []AppParser{
+		{Name: "cisco", Parse: parseCisco, Stage: StageHeader},
+		{Name: "container", Parse: parseContainer, Stage: StageLine},
+		{Name: "grok", Parse: parseGrok},
+		{Name: "sshd", Applications: []string{"sshd"}, Parse: withoutConfig(parseSSHD)},
+		{Name: "sudo", Applications: []string{"sudo"}, Parse: withoutConfig(parseSudo)},
+		{Name: "dovecot", Applications: []string{dovecotApplication}, Parse: withoutConfig(parseDovecot)},
+		{Name: "haproxy", Applications: []string{haproxyApplication}, Parse: withoutConfig(parseHAProxy)},
+		{Name: "postfix", Detect: func(msg *Log) bool { return isPostfix(msg.Application) }, Parse: withoutConfig(parsePostfix)},
+		{Name: "pam", Parse: withoutConfig(parsePAM)},
+		{Name: "netfilter", Parse: withoutConfig(parseNetfilter)},
+		{Name: "accesslog", Parse: parseAccessLog},
+		{Name: "cef", Parse: withoutConfig(parseCEF)},
+		{Name: "leef", Parse: withoutConfig(parseLEEF)},
+		{Name: "fortinet", Parse: withoutConfig(parseFortinet)},
+		{Name: "panos", Parse: withoutConfig(parsePANOS)},
+		{Name: "snare", Parse: withoutConfig(parseSnare)},
+		{Name: "wincollect", Parse: withoutConfig(parseWinCollect)},
+		{Name: "nxlog", Parse: withoutConfig(parseNXLog), Stage: StageJSON},
+	} {
+		mustRegister(p)
+	}
+}
+
+func withoutConfig(parse func(msg *Log) bool) func(msg *Log, config *Config) bool {
+	return func(msg *Log, _ *Config) bool {
+		return parse(msg)
+	}
+}
+
+func parseApp(msg *Log, config *Config) {
 	switch msg.Application {
 	case "auth", "daemon", "kern", "syslog":
 		if _, ok := msg.Metadata[logfileKey]; ok {
-			parseSystemd(msg)
+			parseSystemd(msg, config)
 		}
 	}
+
+	// after systemd may have revealed the application
+	runAppParsers(msg, config, StageText)
 }
 
 // systemd and auth don't come in with the header so we need to add it to parse them
-func parseSystemd(msg *Log) {
-	if m, _ := parseSyslogLine([]byte("<6> " + msg.Text)); m != nil {
+func parseSystemd(msg *Log, config *Config) {
+	if m, _ := parseSyslogLine([]byte("<6> "+msg.Text), config); m != nil {
 		msg.Application = m.Application
 		msg.Text = m.Text
+		if m.ProcID != "" {
+			msg.ProcID = m.ProcID
+			msg.PID = m.PID
+		}
 
 		maps.Copy(msg.Metadata, m.Metadata)
 	}
@@ -0,0 +1,159 @@
+package parser
+
+import (
+	"regexp"
+	"strings"
+)
+
+const (
+	// authFieldPrefix namespaces the normalized authentication fields in
+	// Log.Metadata.
+	authFieldPrefix = "auth."
+
+	authUser       = authFieldPrefix + "user"
+	authSourceIP   = authFieldPrefix + "source_ip"
+	authMethod     = authFieldPrefix + "method"
+	authOutcome    = authFieldPrefix + "outcome"
+	authCommand    = authFieldPrefix + "command"
+	authTTY        = authFieldPrefix + "tty"
+	authPwd        = authFieldPrefix + "pwd"
+	authTargetUser = authFieldPrefix + "target_user"
+	authService    = authFieldPrefix + "service"
+
+	authSuccess       = "success"
+	authFailure       = "failure"
+	authDisconnect    = "disconnect"
+	authSessionOpened = "session_opened"
+	authSessionClosed = "session_closed"
+)
+
+var (
+	// sshdAuthRegex matches the accepted and failed logins of sshd.
+	sshdAuthRegex = regexp.MustCompile(`^(Accepted|Failed) (\S+) for (?:invalid user )?(\S*) from (\S+) port \d+`)
+	// sshdInvalidUserRegex matches the logins of unknown users.
+	sshdInvalidUserRegex = regexp.MustCompile(`^Invalid user (\S*) from (\S+)`)
+	// sshdDisconnectRegex matches the connections closed before or after a
+	// login, with or without the user.
+	sshdDisconnectRegex = regexp.MustCompile(`^(?:Disconnected from|Received disconnect from|Connection closed by|Connection reset by)(?: (?:invalid|authenticating))?(?: user (\S*))? (\S+) port \d+`)
+	// pamRegex matches the messages of PAM modules, `pam_unix(sshd:session): ...`.
+	pamRegex = regexp.MustCompile(`^pam_\w+\(([^:)]+):(\w+)\): (.*)$`)
+	// pamSessionRegex matches the sessions PAM opens and closes.
+	pamSessionRegex = regexp.MustCompile(`^session (opened|closed) for user ([^\s(]+)`)
+)
+
+// parseSSHD extracts the logins and disconnects of sshd.
+func parseSSHD(msg *Log) bool {
+	if m := sshdAuthRegex.FindStringSubmatch(msg.Text); m != nil {
+		outcome := authSuccess
+		if m[1] == "Failed" {
+			outcome = authFailure
+		}
+		setAuth(msg, outcome, m[3], m[4])
+		msg.Metadata[authMethod] = m[2]
+		return true
+	}
+
+	if m := sshdInvalidUserRegex.FindStringSubmatch(msg.Text); m != nil {
+		setAuth(msg, authFailure, m[1], m[2])
+		return true
+	}
+
+	if m := sshdDisconnectRegex.FindStringSubmatch(msg.Text); m != nil {
+		setAuth(msg, authDisconnect, m[1], m[2])
+		return true
+	}
+
+	return false
+}
+
+// sudoFields maps the fields of sudo logs onto the metadata they are kept
+// in.
+var sudoFields = map[string]string{
+	"TTY":     authTTY,
+	"PWD":     authPwd,
+	"USER":    authTargetUser,
+	"COMMAND": authCommand,
+}
+
+// parseSudo extracts the commands run with sudo:
+//
+//	bob : TTY=pts/0 ; PWD=/home/bob ; USER=root ; COMMAND=/usr/bin/apt update
+//
+// Refused commands carry the reason in front of the TTY.
+func parseSudo(msg *Log) bool {
+	user, rest, ok := strings.Cut(strings.TrimSpace(msg.Text), " : ")
+	if !ok || strings.Contains(user, " ") {
+		return false
+	}
+
+	// nothing is changed before the command is known to be there
+	outcome := authSuccess
+	fields := map[string]string{}
+	for _, field := range strings.Split(rest, " ; ") {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok || strings.ContainsRune(key, ' ') {
+			// the reason the command was refused
+			outcome = authFailure
+			continue
+		}
+		fields[key] = value
+	}
+	if _, ok := fields["COMMAND"]; !ok {
+		return false
+	}
+
+	for key, name := range sudoFields {
+		if value, ok := fields[key]; ok {
+			msg.Metadata[name] = value
+			// the metadata parser splits these on spaces
+			delete(msg.Metadata, key)
+		}
+	}
+
+	setAuth(msg, outcome, user, "")
+	msg.Metadata[authMethod] = "sudo"
+	return true
+}
+
+// parsePAM extracts the sessions and authentication failures PAM modules
+// report, of any application.
+func parsePAM(msg *Log) bool {
+	m := pamRegex.FindStringSubmatch(msg.Text)
+	if m == nil {
+		return false
+	}
+
+	switch {
+	case m[2] == "session":
+		session := pamSessionRegex.FindStringSubmatch(m[3])
+		if session == nil {
+			return false
+		}
+		outcome := authSessionOpened
+		if session[1] == "closed" {
+			outcome = authSessionClosed
+		}
+		setAuth(msg, outcome, session[2], "")
+	case strings.HasPrefix(m[3], "authentication failure"):
+		// the details were parsed as metadata already
+		user, _ := msg.Metadata["user"].(string)
+		rhost, _ := msg.Metadata["rhost"].(string)
+		setAuth(msg, authFailure, user, rhost)
+	default:
+		return false
+	}
+
+	msg.Metadata[authService] = m[1]
+	msg.Metadata[authMethod] = "pam"
+	return true
+}
+
+func setAuth(msg *Log, outcome, user, sourceIP string) {
+	msg.Metadata[authOutcome] = outcome
+	if user != "" {
+		msg.Metadata[authUser] = user
+	}
+	if sourceIP != "" {
+		msg.Metadata[authSourceIP] = sourceIP
+	}
+}
@@ -0,0 +1,102 @@
+package parser
+
+import (
+	"maps"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAuth(t *testing.T) {
+	cases := []struct {
+		raw      string
+		metadata map[string]any
+	}{
+		{
+			raw: "<38>Jan 18 11:07:53 host sshd[42]: Accepted publickey for bob from 10.0.0.1 port 51234 ssh2: RSA SHA256:abc",
+			metadata: map[string]any{
+				"auth.outcome": "success", "auth.user": "bob", "auth.source_ip": "10.0.0.1", "auth.method": "publickey",
+			},
+		},
+		{
+			raw: "<38>Jan 18 11:07:53 host sshd[42]: Failed password for invalid user admin from 10.0.0.1 port 51234 ssh2",
+			metadata: map[string]any{
+				"auth.outcome": "failure", "auth.user": "admin", "auth.source_ip": "10.0.0.1", "auth.method": "password",
+			},
+		},
+		{
+			raw: "<38>Jan 18 11:07:53 host sshd[42]: Invalid user admin from 10.0.0.1 port 51234",
+			metadata: map[string]any{
+				"auth.outcome": "failure", "auth.user": "admin", "auth.source_ip": "10.0.0.1",
+			},
+		},
+		{
+			raw: "<38>Jan 18 11:07:53 host sshd[42]: Disconnected from authenticating user root 10.0.0.1 port 51234 [preauth]",
+			metadata: map[string]any{
+				"auth.outcome": "disconnect", "auth.user": "root", "auth.source_ip": "10.0.0.1",
+			},
+		},
+		{
+			raw: "<38>Jan 18 11:07:53 host sshd[42]: Connection closed by 10.0.0.1 port 51234 [preauth]",
+			metadata: map[string]any{
+				"auth.outcome": "disconnect", "auth.source_ip": "10.0.0.1",
+			},
+		},
+		{
+			raw: "<86>Jan 18 11:07:53 host sudo:      bob : TTY=pts/0 ; PWD=/home/bob ; USER=root ; COMMAND=/usr/bin/apt update",
+			metadata: map[string]any{
+				"auth.outcome": "success", "auth.user": "bob", "auth.method": "sudo", "auth.tty": "pts/0",
+				"auth.pwd": "/home/bob", "auth.target_user": "root", "auth.command": "/usr/bin/apt update",
+			},
+		},
+		{
+			raw: "<86>Jan 18 11:07:53 host sudo:      eve : user NOT in sudoers ; TTY=pts/1 ; PWD=/tmp ; USER=root ; COMMAND=/bin/sh",
+			metadata: map[string]any{
+				"auth.outcome": "failure", "auth.user": "eve", "auth.method": "sudo", "auth.tty": "pts/1",
+				"auth.pwd": "/tmp", "auth.target_user": "root", "auth.command": "/bin/sh",
+			},
+		},
+		{
+			raw: "<86>Jan 18 11:07:53 host sshd[42]: pam_unix(sshd:session): session opened for user bob(uid=1000) by (uid=0)",
+			metadata: map[string]any{
+				"auth.outcome": "session_opened", "auth.user": "bob", "auth.method": "pam", "auth.service": "sshd",
+			},
+		},
+		{
+			raw: "<86>Jan 18 11:07:53 host sudo: pam_unix(sudo:session): session closed for user root",
+			metadata: map[string]any{
+				"auth.outcome": "session_closed", "auth.user": "root", "auth.method": "pam", "auth.service": "sudo",
+			},
+		},
+		{
+			raw: "<86>Jan 18 11:07:53 host sshd[42]: pam_unix(sshd:auth): authentication failure; logname= uid=0 euid=0 tty=ssh ruser= rhost=10.0.0.1  user=root",
+			metadata: map[string]any{
+				"logname": "", "uid": int64(0), "euid": int64(0), "tty": "ssh", "ruser": "", "rhost": "10.0.0.1", "user": "root",
+				"auth.outcome": "failure", "auth.user": "root", "auth.source_ip": "10.0.0.1", "auth.method": "pam", "auth.service": "sshd",
+			},
+		},
+		{
+			raw:      "<38>Jan 18 11:07:53 host sshd[42]: Server listening on 0.0.0.0 port 22.",
+			metadata: map[string]any{},
+		},
+	}
+
+	for _, c := range cases {
+		msg := ParseLineWithFallback([]byte(c.raw), "10.1.1.1")
+		require.NotNil(t, msg, c.raw)
+		assert.Equal(t, c.metadata, msg.Metadata, c.raw)
+	}
+}
+
+func TestParseSudoNoMatch(t *testing.T) {
+	// lines without a command are left to the other parsers as they are
+	raw := []byte("<85>Jan 18 11:07:53 host sudo:      bob : 3 incorrect password attempts ; TTY=pts/0 ; PWD=/home/bob ; USER=root")
+	msg := parseLine(raw, "10.1.1.1", defaultConfig)
+	require.NotNil(t, msg)
+	metadata := maps.Clone(msg.Metadata)
+	require.NotEmpty(t, metadata)
+
+	assert.False(t, parseSudo(msg))
+	assert.Equal(t, metadata, msg.Metadata)
+}
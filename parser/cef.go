@@ -0,0 +1,200 @@
+package parser
+
+import (
+	"strconv"
+	"strings"
+)
+
+const (
+	cefPrefix = "CEF:"
+	// cefFieldPrefix namespaces the CEF fields in Log.Metadata.
+	cefFieldPrefix = "cef."
+)
+
+// cefHeaderKeys are the CEF dictionary names of the header fields following
+// the version.
+var cefHeaderKeys = []string{"deviceVendor", "deviceProduct", "deviceVersion", "deviceEventClassId", "name", "severity"}
+
+// parseCEF parses an ArcSight Common Event Format payload in msg.Text:
+//
+//	CEF:Version|Device Vendor|Device Product|Device Version|Device Event Class ID|Name|Severity|Extension
+func parseCEF(msg *Log) bool {
+	text := strings.TrimSpace(msg.Text)
+	if !strings.HasPrefix(text, cefPrefix) {
+		return false
+	}
+
+	header, extension, ok := splitCEFHeader(text[len(cefPrefix):], len(cefHeaderKeys)+1)
+	if !ok {
+		return false
+	}
+
+	msg.Metadata[cefFieldPrefix+"version"] = header[0]
+	for i, key := range cefHeaderKeys {
+		msg.Metadata[cefFieldPrefix+key] = header[i+1]
+	}
+
+	if severity, ok := cefSeverity(header[len(header)-1]); ok {
+		msg.Severity = severity
+	}
+
+	var message string
+	for _, kv := range parseCEFExtension(extension) {
+		if kv[0] == "msg" {
+			message = kv[1]
+		}
+		msg.Metadata[cefFieldPrefix+kv[0]] = intOrString(kv[1])
+	}
+
+	if msg.Application == "" || msg.Application == syntheticApplication {
+		msg.Application = header[2]
+	}
+
+	// the name is the human readable description of the event, unless the
+	// extension carries a more specific message
+	msg.Text = header[5]
+	if message != "" {
+		msg.Text = message
+	}
+
+	return true
+}
+
+// splitCEFHeader splits the first n pipe delimited header fields off s and
+// returns them along with the rest. Pipes and backslashes in the header are
+// escaped with a backslash.
+func splitCEFHeader(s string, n int) ([]string, string, bool) {
+	fields := make([]string, 0, n)
+
+	var field strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\' && i+1 < len(s) && (s[i+1] == '|' || s[i+1] == '\\'):
+			field.WriteByte(s[i+1])
+			i++
+		case c == '|':
+			fields = append(fields, strings.TrimSpace(field.String()))
+			field.Reset()
+			if len(fields) == n {
+				return fields, s[i+1:], true
+			}
+		default:
+			field.WriteByte(c)
+		}
+	}
+
+	return nil, "", false
+}
+
+// parseCEFExtension parses the space separated `key=value` pairs of a CEF
+// extension. Values may contain spaces, so a value ends where the next key
+// starts. Equal signs and backslashes in values are escaped with a backslash,
+// and `\n` and `\r` stand for line breaks.
+func parseCEFExtension(ext string) [][2]string {
+	// find the unescaped equal signs which end a key
+	type keyPos struct {
+		start, eq int
+	}
+	var keys []keyPos
+	for i := 0; i < len(ext); i++ {
+		switch ext[i] {
+		case '\\':
+			i++
+		case '=':
+			start := strings.LastIndexByte(ext[:i], ' ') + 1
+			if start < i && isCEFKey(ext[start:i]) {
+				keys = append(keys, keyPos{start: start, eq: i})
+			}
+		}
+	}
+
+	pairs := make([][2]string, 0, len(keys))
+	for i, k := range keys {
+		end := len(ext)
+		if i+1 < len(keys) {
+			end = keys[i+1].start
+		}
+		pairs = append(pairs, [2]string{ext[k.start:k.eq], unescapeCEFValue(strings.TrimSpace(ext[k.eq+1 : end]))})
+	}
+
+	return pairs
+}
+
+func isCEFKey(key string) bool {
+	for i := 0; i < len(key); i++ {
+		switch c := key[i]; {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '_', c == '.', c == '-', c == '[', c == ']':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func unescapeCEFValue(value string) string {
+	if !strings.Contains(value, "\\") {
+		return value
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' || i+1 == len(value) {
+			b.WriteByte(value[i])
+			continue
+		}
+
+		i++
+		switch value[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		default:
+			b.WriteByte(value[i])
+		}
+	}
+	return b.String()
+}
+
+// intOrString types integer values and keeps everything else as a string.
+func intOrString(value string) any {
+	if value != "" && value[0] != '+' && (value[0] != '0' || len(value) == 1) {
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return n
+		}
+	}
+	return value
+}
+
+// cefSeverity maps the CEF severity, either 0-10 or one of its names, onto the
+// syslog severities.
+func cefSeverity(severity string) (int64, bool) {
+	if n, err := strconv.Atoi(severity); err == nil {
+		switch {
+		case n < 0 || n > 10:
+			return 0, false
+		case n <= 3:
+			severity = "low"
+		case n <= 6:
+			severity = "medium"
+		case n <= 8:
+			severity = "high"
+		default:
+			severity = "very-high"
+		}
+	}
+
+	switch strings.ToLower(severity) {
+	case "low":
+		return Info, true
+	case "medium":
+		return Warning, true
+	case "high":
+		return Error, true
+	case "very-high":
+		return Critical, true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,117 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCEF(t *testing.T) {
+	cases := []struct {
+		raw         string
+		hostname    string
+		application string
+		text        string
+		severity    int64
+		metadata    map[string]any
+	}{
+		{
+			raw:         `<134>Sep 19 08:26:10 host CEF:0|Security|threatmanager|1.0|100|worm successfully stopped|10|src=10.0.0.1 dst=2.1.2.2 spt=1232`,
+			hostname:    "host",
+			application: "threatmanager",
+			text:        "worm successfully stopped",
+			severity:    Error,
+			metadata: map[string]any{
+				"cef.version":            "0",
+				"cef.deviceVendor":       "Security",
+				"cef.deviceProduct":      "threatmanager",
+				"cef.deviceVersion":      "1.0",
+				"cef.deviceEventClassId": "100",
+				"cef.name":               "worm successfully stopped",
+				"cef.severity":           "10",
+				"cef.src":                "10.0.0.1",
+				"cef.dst":                "2.1.2.2",
+				"cef.spt":                int64(1232),
+			},
+		},
+		{
+			raw:         `<134>Sep 19 08:26:10 waf01 modsec: CEF:0|Vendor|Web\|Firewall|2.0|942100|SQL Injection|Medium|act=blocked request=https://example.com/a?b=c&d=e msg=Detected \= in a path with spaces\\here cs1Label=rule cs1=sqli detect\nnext`,
+			hostname:    "waf01",
+			application: "modsec",
+			text:        `Detected = in a path with spaces\here`,
+			severity:    Warning,
+			metadata: map[string]any{
+				"cef.version":            "0",
+				"cef.deviceVendor":       "Vendor",
+				"cef.deviceProduct":      "Web|Firewall",
+				"cef.deviceVersion":      "2.0",
+				"cef.deviceEventClassId": "942100",
+				"cef.name":               "SQL Injection",
+				"cef.severity":           "Medium",
+				"cef.act":                "blocked",
+				"cef.request":            "https://example.com/a?b=c&d=e",
+				"cef.msg":                `Detected = in a path with spaces\here`,
+				"cef.cs1Label":           "rule",
+				"cef.cs1":                "sqli detect\nnext",
+			},
+		},
+		{
+			raw:         `<134>1 2024-01-02T03:04:05Z ids01 suricata 42 - - CEF:1|Corp|IDS|3|7|Port scan|3|`,
+			hostname:    "ids01",
+			application: "suricata",
+			text:        "Port scan",
+			severity:    Info,
+			metadata: map[string]any{
+				"cef.version":            "1",
+				"cef.deviceVendor":       "Corp",
+				"cef.deviceProduct":      "IDS",
+				"cef.deviceVersion":      "3",
+				"cef.deviceEventClassId": "7",
+				"cef.name":               "Port scan",
+				"cef.severity":           "3",
+			},
+		},
+		{
+			raw:         `CEF:0|Corp|IDS|3|7|Port scan|1|src=10.0.0.1`,
+			application: "IDS",
+			text:        "Port scan",
+			severity:    Info,
+			metadata: map[string]any{
+				"cef.version":            "0",
+				"cef.deviceVendor":       "Corp",
+				"cef.deviceProduct":      "IDS",
+				"cef.deviceVersion":      "3",
+				"cef.deviceEventClassId": "7",
+				"cef.name":               "Port scan",
+				"cef.severity":           "1",
+				"cef.src":                "10.0.0.1",
+			},
+		},
+	}
+
+	for _, c := range cases {
+		msg := ParseLineWithFallback([]byte(c.raw), "10.1.1.1")
+		require.NotNil(t, msg, c.raw)
+
+		if c.hostname != "" {
+			assert.Equal(t, c.hostname, msg.Hostname, c.raw)
+		}
+		assert.Equal(t, c.application, msg.Application, c.raw)
+		assert.Equal(t, c.text, msg.Text, c.raw)
+		assert.Equal(t, c.severity, msg.Severity, c.raw)
+		assert.Equal(t, c.metadata, msg.Metadata, c.raw)
+	}
+}
+
+func TestParseCEFInvalid(t *testing.T) {
+	for _, raw := range []string{
+		"<134>Sep 19 08:26:10 host app: CEF:0|Security|threatmanager|1.0",
+		"<134>Sep 19 08:26:10 host app: not CEF:0|a|b|c|d|e|f|",
+	} {
+		msg := ParseLineWithFallback([]byte(raw), "10.1.1.1")
+		require.NotNil(t, msg, raw)
+		assert.Equal(t, "app", msg.Application, raw)
+		assert.NotContains(t, msg.Metadata, "cef.version", raw)
+	}
+}
@@ -0,0 +1,292 @@
+package parser
+
+import (
+	"net/netip"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ciscoFieldPrefix namespaces the Cisco fields in Log.Metadata.
+const ciscoFieldPrefix = "cisco."
+
+var (
+	// ciscoMnemonicRegex matches the `%FACILITY-SEVERITY-MNEMONIC:` message
+	// tag of IOS, IOS-XE, NX-OS and ASA, like `%LINK-3-UPDOWN:` or
+	// `%ASA-6-302013:`.
+	ciscoMnemonicRegex = regexp.MustCompile(`(?:^|[\s:])%([A-Z][A-Z0-9_]*(?:-[A-Z][A-Z0-9_]*)*)-([0-7])-([A-Z0-9_]+)\s?:`)
+
+	// ciscoTimeLayouts are the `service timestamps` formats of IOS and
+	// NX-OS, and the `logging timestamp` formats of ASA. Fractional
+	// seconds are accepted by all of these.
+	ciscoTimeLayouts = []string{
+		"Jan _2 15:04:05",
+		"Jan _2 2006 15:04:05",
+		"2006 Jan _2 15:04:05",
+		time.RFC3339,
+		"2006-01-02T15:04:05",
+	}
+
+	// asaFacilities send the ASA connection messages.
+	asaFacilities = map[string]bool{"ASA": true, "FTD": true, "FWSM": true, "PIX": true}
+
+	// asaEndpointRegex matches the `interface:address/port` endpoints of ASA
+	// messages, along with the mapped `(address/port)` following them.
+	asaEndpointRegex = regexp.MustCompile(`\b(for|from|src|to|dst) (?:([A-Za-z][\w.-]*):)?([0-9A-Fa-f.:]+)/(\d+)(?: ?\(([0-9A-Fa-f.:]+)/(\d+)\))?`)
+
+	// asaFields are the remaining connection variables of ASA messages.
+	asaFields = []struct {
+		key   string
+		regex *regexp.Regexp
+	}{
+		{"connectionId", regexp.MustCompile(`\bconnection (\d+)\b`)},
+		{"direction", regexp.MustCompile(`\b(inbound|outbound)\b`)},
+		{"protocol", regexp.MustCompile(`\b(TCP|UDP|ICMP|GRE|SCTP|tcp|udp|icmp|gre|sctp)\b`)},
+		{"duration", regexp.MustCompile(`\bduration (\d+:\d{2}:\d{2})\b`)},
+		{"bytes", regexp.MustCompile(`\bbytes (\d+)\b`)},
+	}
+)
+
+// parseCisco parses messages of Cisco devices, which are identified by their
+// `%FACILITY-SEVERITY-MNEMONIC:` tag:
+//
+//	<189>123: router1: *Mar  1 00:00:12.345 UTC: %LINK-3-UPDOWN: Interface Gi0/1, changed state to up
+//	<166>Jan 18 2024 11:07:53 asa01 : %ASA-6-302013: Built outbound TCP connection ...
+//	<189>: 2024 Jan 18 11:07:53 UTC: %ETHPORT-5-IF_UP: Interface Ethernet1/1 is up
+//
+// The header in front of the tag consists of an optional sequence number,
+// hostname and timestamp, each followed by a colon. A timestamp starting
+// with `*` isn't authoritative, so the time of receipt is kept instead.
+func parseCisco(msg *Log, _ *Config) bool {
+	// cheap check before running the regex on every message
+	if strings.IndexByte(msg.Text, '%') < 0 {
+		return false
+	}
+
+	data := []byte(msg.Text)
+	i := 0
+	l := len(data)
+
+	// only touch msg once the message is known to be from a Cisco device
+	cisco := &Log{
+		Severity: Unknown,
+		Metadata: map[string]any{},
+	}
+
+	if !parsePriority(cisco, data, &i, &l) {
+		return false
+	}
+	loc := ciscoMnemonicRegex.FindSubmatchIndex(data[i : i+l])
+	if loc == nil {
+		return false
+	}
+
+	// the regex may have consumed the separator in front of the `%`
+	tagStart := i + loc[2] - 1
+	if !parseCiscoHeader(cisco, string(data[i:tagStart])) {
+		return false
+	}
+
+	facility := string(data[i+loc[2] : i+loc[3]])
+	severity := data[i+loc[4]] - '0'
+	mnemonic := string(data[i+loc[6] : i+loc[7]])
+
+	valid, textData := processText(data[i+loc[1] : i+l])
+	if !valid {
+		return false
+	}
+	cisco.Text = strings.TrimSpace(textData)
+
+	cisco.Severity = int64(severity)
+	cisco.Metadata[ciscoFieldPrefix+"facility"] = facility
+	cisco.Metadata[ciscoFieldPrefix+"severity"] = int64(severity)
+	cisco.Metadata[ciscoFieldPrefix+"mnemonic"] = mnemonic
+	cisco.Metadata[ciscoFieldPrefix+"messageId"] = string(data[tagStart+1 : i+loc[7]])
+
+	if cisco.Application == "" {
+		cisco.Application = facility
+	}
+	if cisco.Timestamp == 0 {
+		cisco.Timestamp = time.Now().UnixNano()
+	}
+
+	if asaFacilities[facility] {
+		parseASAConnection(cisco)
+	}
+
+	*msg = *cisco
+	return true
+}
+
+// parseCiscoHeader parses the colon separated fields in front of the message
+// tag. It fails on anything that isn't a sequence number, hostname, tag or
+// timestamp, so that other messages mentioning a tag are left alone.
+func parseCiscoHeader(msg *Log, header string) bool {
+	header = strings.TrimSuffix(strings.TrimSpace(header), ":")
+
+	for field := range strings.SplitSeq(header, ": ") {
+		field = strings.Trim(field, " :")
+
+		switch {
+		case field == "":
+		case isDigits(field) && msg.Timestamp == 0 && msg.Hostname == "":
+			msg.Metadata["SequenceID"] = field
+		case parseCiscoTime(msg, field):
+		case strings.ContainsAny(field, " \t"):
+			return false
+		case strings.Contains(field, "["):
+			var procID string
+			msg.Application, procID = parseApplication(field)
+			setProcID(msg, procID)
+		case msg.Hostname == "":
+			msg.Hostname = field
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// parseCiscoTime parses a timestamp, optionally followed by a time zone
+// abbreviation and the hostname. Abbreviations other than UTC are ambiguous,
+// so those timestamps are placed in the time zone configured for the sender.
+func parseCiscoTime(msg *Log, field string) bool {
+	authoritative := true
+	switch field[0] {
+	case '*':
+		authoritative = false
+		field = field[1:]
+	case '.':
+		// authoritative, but not synchronised at the moment
+		field = field[1:]
+	}
+
+	tokens := strings.Fields(field)
+	for n := min(len(tokens), 5); n > 0; n-- {
+		timeTokens := tokens[:n]
+		zone := ""
+		if last := timeTokens[n-1]; isZoneAbbreviation(last) {
+			zone = last
+			timeTokens = timeTokens[:n-1]
+		}
+
+		ts, zoneless, ok := parseCiscoTimestamp(strings.Join(timeTokens, " "))
+		if !ok || len(tokens)-n > 1 {
+			continue
+		}
+
+		if len(tokens) > n {
+			msg.Hostname = tokens[n]
+		}
+
+		if !authoritative {
+			msg.Metadata[ciscoFieldPrefix+"deviceTime"] = strings.TrimSpace(field)
+			return true
+		}
+
+		switch {
+		case !zoneless:
+		case zone == "UTC" || zone == "GMT" || zone == "Z":
+			ts = fromWallClock(ts, time.UTC, time.Now())
+		default:
+			msg.wallClock = ts
+			msg.hasWallClock = true
+			ts = fromWallClock(ts, time.Local, time.Now())
+		}
+		msg.Timestamp = ts.UnixNano()
+		return true
+	}
+
+	return false
+}
+
+func parseCiscoTimestamp(value string) (time.Time, bool, bool) {
+	for _, layout := range ciscoTimeLayouts {
+		if ts, err := time.Parse(layout, value); err == nil {
+			return ts, !layoutHasZone(layout), true
+		}
+	}
+	return time.Time{}, false, false
+}
+
+func isZoneAbbreviation(s string) bool {
+	if len(s) < 1 || len(s) > 5 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < 'A' || s[i] > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// parseASAConnection extracts the connection variables of ASA messages like
+//
+//	Built outbound TCP connection 1234 for outside:198.51.100.7/443 (198.51.100.7/443) to inside:10.0.0.5/51234 (203.0.113.5/51234)
+//	Teardown TCP connection 1234 for outside:198.51.100.7/443 to inside:10.0.0.5/51234 duration 0:01:02 bytes 5678 TCP FINs
+//	Deny tcp src outside:198.51.100.7/4711 dst inside:10.0.0.5/22 by access-group "outside_in"
+//
+// The endpoint following `for`, `from` or `src` is the source and the one
+// following `to` or `dst` the destination, except for outbound connections
+// where the ASA names the responder first.
+func parseASAConnection(msg *Log) {
+	for _, field := range asaFields {
+		if m := field.regex.FindStringSubmatch(msg.Text); m != nil {
+			value := m[1]
+			if field.key == "protocol" {
+				value = strings.ToLower(value)
+			}
+			msg.Metadata[ciscoFieldPrefix+field.key] = intOrString(value)
+		}
+	}
+
+	src, dst := "src", "dst"
+	if msg.Metadata[ciscoFieldPrefix+"direction"] == "outbound" {
+		src, dst = dst, src
+	}
+
+	seen := map[string]bool{}
+	for _, m := range asaEndpointRegex.FindAllStringSubmatch(msg.Text, -1) {
+		side := src
+		if m[1] == "to" || m[1] == "dst" {
+			side = dst
+		}
+		if seen[side] {
+			continue
+		}
+		seen[side] = true
+
+		iface, addr := m[2], m[3]
+		// an IPv6 address without an interface looks like one with
+		if iface != "" && strings.Contains(addr, ":") {
+			if _, err := netip.ParseAddr(iface + ":" + addr); err == nil {
+				iface, addr = "", iface+":"+addr
+			}
+		}
+		if _, err := netip.ParseAddr(addr); err != nil {
+			continue
+		}
+
+		prefix := ciscoFieldPrefix + side
+		msg.Metadata[prefix+"Ip"] = addr
+		msg.Metadata[prefix+"Port"] = intOrString(m[4])
+		if iface != "" {
+			msg.Metadata[prefix+"Interface"] = iface
+		}
+		if m[5] != "" {
+			msg.Metadata[prefix+"MappedIp"] = m[5]
+			msg.Metadata[prefix+"MappedPort"] = intOrString(m[6])
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package parser
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCisco(t *testing.T) {
+	cases := []struct {
+		raw         string
+		hostname    string
+		application string
+		text        string
+		severity    int64
+		time        time.Time
+		metadata    map[string]any
+	}{
+		{
+			raw:         "<189>000123: router1: *Mar  1 00:00:12.345 UTC: %LINK-3-UPDOWN: Interface GigabitEthernet0/1, changed state to up",
+			hostname:    "router1",
+			application: "LINK",
+			text:        "Interface GigabitEthernet0/1, changed state to up",
+			severity:    Error,
+			metadata: map[string]any{
+				"SequenceID":       "000123",
+				"cisco.facility":   "LINK",
+				"cisco.severity":   int64(3),
+				"cisco.mnemonic":   "UPDOWN",
+				"cisco.messageId":  "LINK-3-UPDOWN",
+				"cisco.deviceTime": "Mar  1 00:00:12.345 UTC",
+			},
+		},
+		{
+			raw:         "<189>45: Jan 18 2024 11:07:53.123 UTC: %SYS-5-CONFIG_I: Configured from console by admin on vty0 (10.0.0.1)",
+			application: "SYS",
+			text:        "Configured from console by admin on vty0 (10.0.0.1)",
+			severity:    Info,
+			time:        time.Date(2024, 1, 18, 11, 7, 53, 123000000, time.UTC),
+			metadata: map[string]any{
+				"SequenceID":      "45",
+				"cisco.facility":  "SYS",
+				"cisco.severity":  int64(5),
+				"cisco.mnemonic":  "CONFIG_I",
+				"cisco.messageId": "SYS-5-CONFIG_I",
+			},
+		},
+		{
+			raw:         "<189>: 2024 Jan 18 11:07:53 UTC: %ETHPORT-5-IF_UP: Interface Ethernet1/1 is up in mode access",
+			application: "ETHPORT",
+			text:        "Interface Ethernet1/1 is up in mode access",
+			severity:    Info,
+			time:        time.Date(2024, 1, 18, 11, 7, 53, 0, time.UTC),
+			metadata: map[string]any{
+				"cisco.facility":  "ETHPORT",
+				"cisco.severity":  int64(5),
+				"cisco.mnemonic":  "IF_UP",
+				"cisco.messageId": "ETHPORT-5-IF_UP",
+			},
+		},
+		{
+			raw:         "<166>Jan 18 2024 11:07:53 asa01 : %ASA-6-302013: Built outbound TCP connection 1234 for outside:198.51.100.7/443 (198.51.100.7/443) to inside:10.0.0.5/51234 (203.0.113.5/51234)",
+			hostname:    "asa01",
+			application: "ASA",
+			text:        "Built outbound TCP connection 1234 for outside:198.51.100.7/443 (198.51.100.7/443) to inside:10.0.0.5/51234 (203.0.113.5/51234)",
+			severity:    Info,
+			time:        time.Date(2024, 1, 18, 11, 7, 53, 0, time.Local),
+			metadata: map[string]any{
+				"cisco.facility":      "ASA",
+				"cisco.severity":      int64(6),
+				"cisco.mnemonic":      "302013",
+				"cisco.messageId":     "ASA-6-302013",
+				"cisco.connectionId":  int64(1234),
+				"cisco.direction":     "outbound",
+				"cisco.protocol":      "tcp",
+				"cisco.srcIp":         "10.0.0.5",
+				"cisco.srcPort":       int64(51234),
+				"cisco.srcInterface":  "inside",
+				"cisco.srcMappedIp":   "203.0.113.5",
+				"cisco.srcMappedPort": int64(51234),
+				"cisco.dstIp":         "198.51.100.7",
+				"cisco.dstPort":       int64(443),
+				"cisco.dstInterface":  "outside",
+				"cisco.dstMappedIp":   "198.51.100.7",
+				"cisco.dstMappedPort": int64(443),
+			},
+		},
+		{
+			raw:         "<164>%ASA-4-106023: Deny tcp src outside:2001:db8::7/4711 dst inside:10.0.0.5/22 by access-group \"outside_in\" [0x0, 0x0]",
+			application: "ASA",
+			text:        "Deny tcp src outside:2001:db8::7/4711 dst inside:10.0.0.5/22 by access-group \"outside_in\" [0x0, 0x0]",
+			severity:    Warning,
+			metadata: map[string]any{
+				"cisco.facility":     "ASA",
+				"cisco.severity":     int64(4),
+				"cisco.mnemonic":     "106023",
+				"cisco.messageId":    "ASA-4-106023",
+				"cisco.protocol":     "tcp",
+				"cisco.srcIp":        "2001:db8::7",
+				"cisco.srcPort":      int64(4711),
+				"cisco.srcInterface": "outside",
+				"cisco.dstIp":        "10.0.0.5",
+				"cisco.dstPort":      int64(22),
+				"cisco.dstInterface": "inside",
+			},
+		},
+		{
+			raw:         "<190>Apr 15 2007 21:28:13: %PIX-6-302014: Teardown TCP connection 1688438 for bloomberg-net:1.2.3.4/8294 to inside:5.6.7.8/3639 duration 0:07:01 bytes 16975 TCP FINs",
+			application: "PIX",
+			text:        "Teardown TCP connection 1688438 for bloomberg-net:1.2.3.4/8294 to inside:5.6.7.8/3639 duration 0:07:01 bytes 16975 TCP FINs",
+			severity:    Info,
+			time:        time.Date(2007, 4, 15, 21, 28, 13, 0, time.Local),
+			metadata: map[string]any{
+				"cisco.facility":     "PIX",
+				"cisco.severity":     int64(6),
+				"cisco.mnemonic":     "302014",
+				"cisco.messageId":    "PIX-6-302014",
+				"cisco.connectionId": int64(1688438),
+				"cisco.protocol":     "tcp",
+				"cisco.duration":     "0:07:01",
+				"cisco.bytes":        int64(16975),
+				"cisco.srcIp":        "1.2.3.4",
+				"cisco.srcPort":      int64(8294),
+				"cisco.srcInterface": "bloomberg-net",
+				"cisco.dstIp":        "5.6.7.8",
+				"cisco.dstPort":      int64(3639),
+				"cisco.dstInterface": "inside",
+			},
+		},
+	}
+
+	for _, c := range cases {
+		msg := ParseLineWithFallback([]byte(c.raw), "10.1.1.1")
+		require.NotNil(t, msg, c.raw)
+
+		if c.hostname != "" {
+			assert.Equal(t, c.hostname, msg.Hostname, c.raw)
+		}
+		if !c.time.IsZero() {
+			assert.Equal(t, c.time.UnixNano(), msg.Timestamp, c.raw)
+		}
+		assert.Equal(t, c.application, msg.Application, c.raw)
+		assert.Equal(t, c.text, msg.Text, c.raw)
+		assert.Equal(t, c.severity, msg.Severity, c.raw)
+		assert.Equal(t, c.metadata, msg.Metadata, c.raw)
+	}
+}
+
+func TestParseCiscoUnrelated(t *testing.T) {
+	// a tag in the text of another application's message isn't a Cisco header
+	raw := "<13>Jan 18 11:07:53 host app: forwarded %LINK-3-UPDOWN: message"
+	msg := ParseLineWithFallback([]byte(raw), "10.1.1.1")
+	require.NotNil(t, msg)
+	assert.Equal(t, "host", msg.Hostname)
+	assert.Equal(t, "app", msg.Application)
+	assert.NotContains(t, msg.Metadata, "cisco.facility")
+}
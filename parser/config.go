@@ -0,0 +1,321 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"path"
+	"strings"
+	"time"
+)
+
+// defaultConfig is used by the package level parsing functions.
+var defaultConfig = mustCompile(&Config{})
+
+// Config holds the settings of a Parser. The zero value is valid and applies
+// the defaults.
+type Config struct {
+	// TimeZone is the IANA name of the zone that timestamps sent without an
+	// offset, like RFC 3164 ones, are interpreted in. Defaults to the local
+	// time zone.
+	TimeZone string `json:"timeZone,omitempty"`
+	// TimeZones override TimeZone for specific senders. The first matching
+	// rule wins.
+	TimeZones []TimeZoneRule `json:"timeZones,omitempty"`
+	// TimestampLayouts maps application names to the time layouts, in the
+	// format of the time package, that are tried first when parsing the
+	// timestamp of a JSON message. The layouts under "*" apply to all
+	// applications without an entry of their own.
+	TimestampLayouts map[string][]string `json:"timestampLayouts,omitempty"`
+	// AccessLogFormats maps application names to the nginx log_format of
+	// their access logs. Access logs of nginx, httpd and apache2 are parsed
+	// in the Combined or Common Log Format when there is no entry.
+	AccessLogFormats map[string]string `json:"accessLogFormats,omitempty"`
+	// AccessLogTimestamp uses the timestamp of an access log line as the
+	// timestamp of the message, instead of the one in the syslog header.
+	AccessLogTimestamp bool `json:"accessLogTimestamp,omitempty"`
+	// Parsers enables or disables the application parsers of the given
+	// names, overriding AppParser.Disabled. The built-in parsers are cisco,
+	// container, grok, sshd, sudo, dovecot, haproxy, postfix, pam, netfilter,
+	// accesslog, cef, leef, fortinet, panos, snare, wincollect and nxlog.
+	Parsers map[string]bool `json:"parsers,omitempty"`
+	// GrokRules parse the messages of the matching applications and hosts
+	// with Grok expressions.
+	GrokRules []GrokRule `json:"grokRules,omitempty"`
+	// GrokPatternFiles are files of patterns, one `NAME pattern` per line,
+	// that GrokRules may refer to in addition to the standard ones.
+	GrokPatternFiles []string `json:"grokPatternFiles,omitempty"`
+	// KeepRaw stores the line exactly as it was received in Log.Raw.
+	KeepRaw bool `json:"keepRaw,omitempty"`
+	// MaxRawSize caps Log.Raw to the given number of bytes, cut at the
+	// start of a character. Zero means no limit.
+	MaxRawSize int `json:"maxRawSize,omitempty"`
+	// JSONFields maps application names to the fields of their JSON
+	// messages that hold the timestamp, hostname, application, text and
+	// severity. The fields under "*" apply to all applications without an
+	// entry of their own. JSON lines without a syslog header go by the
+	// application in the message.
+	JSONFields map[string]JSONFields `json:"jsonFields,omitempty"`
+	// NestedJSON keeps the objects and arrays of JSON messages as nested
+	// maps and slices in Log.Metadata, instead of flattening them into keys
+	// like `a.b[0]`.
+	NestedJSON bool `json:"nestedJSON,omitempty"`
+	// MaxJSONDepth is the number of levels of objects and arrays that are
+	// nested. Deeper ones are kept as their JSON text. Defaults to 5.
+	MaxJSONDepth int `json:"maxJSONDepth,omitempty"`
+	// MaxJSONFields is the number of fields, including the keys and elements
+	// of nested objects and arrays, a message gets from JSON in nested mode.
+	// Objects and arrays that would exceed it are kept as their JSON text,
+	// and the top-level fields beyond it are kept together in the
+	// json.remainder field, as a JSON object. Defaults to 1000.
+	MaxJSONFields int `json:"maxJSONFields,omitempty"`
+	// JSONAfterPrefix parses a JSON object ending a message text that starts
+	// with something else, like `app: {...}`. The text before the object is
+	// kept in the json.prefix field, or as the text if the object has no
+	// message of its own.
+	JSONAfterPrefix bool `json:"jsonAfterPrefix,omitempty"`
+	// KeepNulls stores the null values of JSON messages as nil, instead of
+	// leaving the fields out.
+	KeepNulls bool `json:"keepNulls,omitempty"`
+	// LegacyMetadata extracts `key=value` pairs from the message text with
+	// the scanner used before logfmt support, for compatibility.
+	LegacyMetadata bool `json:"legacyMetadata,omitempty"`
+	// PartialTimeout is how long a container log message that was split
+	// into partial lines waits for its next part before it is emitted as
+	// far as it got. Defaults to 5s.
+	PartialTimeout Duration `json:"partialTimeout,omitempty"`
+	// MaxPartialSize is the size in bytes up to which partial lines are
+	// reassembled. Defaults to 1 MiB.
+	MaxPartialSize int `json:"maxPartialSize,omitempty"`
+	// MaxPendingPartials is the number of messages whose partial lines are
+	// reassembled at the same time. Once it is reached, the one continued
+	// the longest time ago is emitted as far as it got. Defaults to 10000.
+	MaxPendingPartials int `json:"maxPendingPartials,omitempty"`
+	// Multiline joins the lines of messages like stack traces, which are
+	// sent as a message per line. The first matching rule applies.
+	Multiline []MultilineRule `json:"multiline,omitempty"`
+	// MaxPendingMultiline is the number of messages whose lines are joined at
+	// the same time. Once it is reached, the one continued the longest time
+	// ago is emitted as far as it got. Defaults to 10000.
+	MaxPendingMultiline int `json:"maxPendingMultiline,omitempty"`
+	// MailCorrelation emits a summary of every message passing a Postfix
+	// queue, in addition to the lines it is assembled from, once the message
+	// was removed from the queue or after MailTimeout.
+	MailCorrelation bool `json:"mailCorrelation,omitempty"`
+	// MailTimeout is how long the summary of a message waits for its next
+	// line before it is emitted as far as it got. Defaults to 10m.
+	MailTimeout Duration `json:"mailTimeout,omitempty"`
+	// MaxPendingMails is the number of messages summarized at the same time.
+	// Once it is reached, the summary of the one heard of the longest time
+	// ago is emitted as far as it got. Defaults to 10000.
+	MaxPendingMails int `json:"maxPendingMails,omitempty"`
+	// ResolveHostnames looks up the PTR record of the source address and
+	// stores it in Log.ResolvedHostname. Lookups happen in the background, so
+	// messages from an address that isn't cached yet go without.
+	ResolveHostnames bool `json:"resolveHostnames,omitempty"`
+	// ResolveCacheSize is the number of addresses whose names are cached.
+	ResolveCacheSize int `json:"resolveCacheSize,omitempty"`
+	// ResolveTTL is how long a resolved name is cached.
+	ResolveTTL Duration `json:"resolveTTL,omitempty"`
+	// ResolveNegativeTTL is how long a failed lookup is cached.
+	ResolveNegativeTTL Duration `json:"resolveNegativeTTL,omitempty"`
+	// ResolveTimeout bounds a single lookup.
+	ResolveTimeout Duration `json:"resolveTimeout,omitempty"`
+	// MaxConcurrentResolves bounds the number of lookups in flight.
+	MaxConcurrentResolves int `json:"maxConcurrentResolves,omitempty"`
+	// Resolver is used for the lookups. Defaults to net.DefaultResolver.
+	Resolver Resolver `json:"-"`
+
+	location         *time.Location
+	zoneRules        []zoneRule
+	hostnames        *hostnameCache
+	accessLogFormats map[string]*accessLogFormat
+	grokRules        []grokRule
+	jsonFields       map[string]*jsonFieldMap
+	multilineRules   []multilineRule
+}
+
+// Duration is a time.Duration that is written as a string like "1m30s" in
+// JSON.
+type Duration time.Duration
+
+// MarshalJSON implements json.Marshaler.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(v)
+	return nil
+}
+
+// orDefault returns d, or def if d is not positive.
+func (d Duration) orDefault(def time.Duration) time.Duration {
+	if d <= 0 {
+		return def
+	}
+	return time.Duration(d)
+}
+
+// TimeZoneRule assigns a time zone to the senders that match either CIDR,
+// checked against the source address, or Hostname, checked against the
+// hostname in the message. Hostname may contain `*` wildcards.
+type TimeZoneRule struct {
+	CIDR     string `json:"cidr,omitempty"`
+	Hostname string `json:"hostname,omitempty"`
+	TimeZone string `json:"timeZone"`
+}
+
+type zoneRule struct {
+	network  *net.IPNet
+	hostname string
+	location *time.Location
+}
+
+func (r zoneRule) matches(remoteAddr, hostname string) bool {
+	if r.network != nil {
+		ip := net.ParseIP(remoteAddr)
+		return ip != nil && r.network.Contains(ip)
+	}
+	ok, _ := path.Match(r.hostname, strings.ToLower(hostname))
+	return ok
+}
+
+func mustCompile(config *Config) *Config {
+	if err := config.compile(); err != nil {
+		panic(err)
+	}
+	return config
+}
+
+// compile validates the config and prepares it for use.
+func (c *Config) compile() (err error) {
+	c.location = time.Local
+	if c.TimeZone != "" {
+		if c.location, err = time.LoadLocation(c.TimeZone); err != nil {
+			return fmt.Errorf("time zone %q: %w", c.TimeZone, err)
+		}
+	}
+
+	if c.MaxRawSize < 0 {
+		return fmt.Errorf("max raw size must not be negative, got %d", c.MaxRawSize)
+	}
+	if c.MaxJSONDepth < 0 || c.MaxJSONFields < 0 {
+		return fmt.Errorf("JSON limits must not be negative, got depth %d and %d fields", c.MaxJSONDepth, c.MaxJSONFields)
+	}
+	if c.MaxPartialSize < 0 {
+		return fmt.Errorf("max partial size must not be negative, got %d", c.MaxPartialSize)
+	}
+	if c.MaxPendingPartials < 0 {
+		return fmt.Errorf("max pending partials must not be negative, got %d", c.MaxPendingPartials)
+	}
+	if c.MaxPendingMails < 0 {
+		return fmt.Errorf("max pending mails must not be negative, got %d", c.MaxPendingMails)
+	}
+	if c.MaxPendingMultiline < 0 {
+		return fmt.Errorf("max pending multiline messages must not be negative, got %d", c.MaxPendingMultiline)
+	}
+
+	if c.ResolveHostnames {
+		resolver := c.Resolver
+		if resolver == nil {
+			resolver = net.DefaultResolver
+		}
+		c.hostnames = newHostnameCache(resolver,
+			orDefault(c.ResolveCacheSize, defaultResolveCacheSize),
+			c.ResolveTTL.orDefault(defaultResolveTTL),
+			c.ResolveNegativeTTL.orDefault(defaultResolveNegativeTTL),
+			c.ResolveTimeout.orDefault(defaultResolveTimeout),
+			orDefault(c.MaxConcurrentResolves, defaultMaxConcurrentResolve),
+		)
+	}
+
+	c.zoneRules = make([]zoneRule, 0, len(c.TimeZones))
+	for _, tz := range c.TimeZones {
+		var rule zoneRule
+		if rule.location, err = time.LoadLocation(tz.TimeZone); err != nil {
+			return fmt.Errorf("time zone %q: %w", tz.TimeZone, err)
+		}
+
+		switch {
+		case tz.CIDR != "" && tz.Hostname != "":
+			return fmt.Errorf("time zone %q: rule must not set both cidr and hostname", tz.TimeZone)
+		case tz.CIDR != "":
+			if _, rule.network, err = net.ParseCIDR(tz.CIDR); err != nil {
+				return fmt.Errorf("time zone %q: %w", tz.TimeZone, err)
+			}
+		case tz.Hostname != "":
+			rule.hostname = strings.ToLower(tz.Hostname)
+			if _, err = path.Match(rule.hostname, ""); err != nil {
+				return fmt.Errorf("time zone %q: hostname %q: %w", tz.TimeZone, tz.Hostname, err)
+			}
+		default:
+			return fmt.Errorf("time zone %q: rule must set either cidr or hostname", tz.TimeZone)
+		}
+
+		c.zoneRules = append(c.zoneRules, rule)
+	}
+
+	for name := range c.Parsers {
+		if !isRegistered(name) {
+			return fmt.Errorf("parser %q is not registered", name)
+		}
+	}
+
+	if c.jsonFields, err = compileJSONFieldMaps(c.JSONFields); err != nil {
+		return err
+	}
+	if c.multilineRules, err = compileMultilineRules(c.Multiline); err != nil {
+		return err
+	}
+	if c.grokRules, err = compileGrokRules(c.GrokRules, c.GrokPatternFiles); err != nil {
+		return err
+	}
+
+	c.accessLogFormats = make(map[string]*accessLogFormat, len(c.AccessLogFormats))
+	for app, format := range c.AccessLogFormats {
+		if c.accessLogFormats[app], err = compileAccessLogFormat(format); err != nil {
+			return fmt.Errorf("access log format of %q: %w", app, err)
+		}
+	}
+
+	return nil
+}
+
+// timeZone returns the location timestamps without an offset coming from the
+// given sender are in.
+func (c *Config) timeZone(remoteAddr, hostname string) *time.Location {
+	for _, rule := range c.zoneRules {
+		if rule.matches(remoteAddr, hostname) {
+			return rule.location
+		}
+	}
+	return c.location
+}
+
+// parserEnabled reports whether the application parser p runs.
+func (c *Config) parserEnabled(p *AppParser) bool {
+	if enabled, ok := c.Parsers[p.Name]; ok {
+		return enabled
+	}
+	return !p.Disabled
+}
+
+// orDefault returns v, or def if v is not positive.
+func orDefault(v, def int) int {
+	if v <= 0 {
+		return def
+	}
+	return v
+}
@@ -0,0 +1,99 @@
+package parser
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	containerIDKey    = "container.id"
+	containerNameKey  = "container.name"
+	containerImageKey = "container.image"
+	streamKey         = "stream"
+	// dockerApplication is the application of messages that are only tagged
+	// with the container ID.
+	dockerApplication = "docker"
+)
+
+var (
+	// dockerIDRegex matches full container IDs and the prefixes of them tag
+	// templates like `{{.ID}}` may shorten them to.
+	dockerIDRegex = regexp.MustCompile(`^[0-9a-f]{6,64}$`)
+	// criRegex matches the `<time> <stream> <P|F> <content>` lines the CRI
+	// container runtimes write.
+	criRegex = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2}T\S+) (stdout|stderr) ([PF])(?: |$)`)
+)
+
+// isCRI reports whether data is a line of a CRI container log.
+func isCRI(data []byte) bool {
+	return criRegex.Match(data)
+}
+
+// parseContainer extracts the container details of messages sent by Docker's
+// syslog driver or read from the log files of a CRI runtime.
+func parseContainer(msg *Log, config *Config) bool {
+	docker := parseDockerTag(msg)
+	cri := parseCRI(msg, config)
+	return docker || cri
+}
+
+// parseDockerTag parses the tags of Docker's syslog driver: the default
+// `{{.ID}}`, or templates like `docker/{{.ID}}`, `docker/{{.Name}}`,
+// `{{.Name}}/{{.ID}}` and `{{.ImageName}}/{{.Name}}/{{.ID}}`. The container
+// name becomes the application, so that messages can be told apart by
+// service rather than by container.
+func parseDockerTag(msg *Log) bool {
+	parts := strings.Split(msg.Application, "/")
+	last := parts[len(parts)-1]
+
+	if !dockerIDRegex.MatchString(last) {
+		if len(parts) == 2 && parts[0] == dockerApplication && last != "" {
+			msg.Metadata[containerNameKey] = last
+			msg.Application = last
+			return true
+		}
+		return false
+	}
+
+	msg.Metadata[containerIDKey] = last
+	if len(parts) == 1 || (len(parts) == 2 && parts[0] == dockerApplication) {
+		msg.Application = dockerApplication
+		return true
+	}
+
+	name := parts[len(parts)-2]
+	msg.Metadata[containerNameKey] = name
+	if len(parts) > 2 {
+		// image names may contain slashes themselves
+		msg.Metadata[containerImageKey] = strings.Join(parts[:len(parts)-2], "/")
+	}
+	msg.Application = name
+	return true
+}
+
+// parseCRI unwraps a CRI log line in msg.Text. The runtime splits long lines
+// into partial ones, flagged `P`, followed by a final one flagged `F`, which
+// Parser reassembles. The timestamp of the line is the time the container
+// wrote it, so it replaces the one of the message.
+func parseCRI(msg *Log, config *Config) bool {
+	m := criRegex.FindStringSubmatchIndex(msg.Text)
+	if m == nil {
+		return false
+	}
+
+	if ts, err := time.Parse(time.RFC3339Nano, msg.Text[m[2]:m[3]]); err == nil {
+		msg.Timestamp = ts.UnixNano()
+		msg.hasWallClock = false
+	}
+	msg.Metadata[streamKey] = msg.Text[m[4]:m[5]]
+	msg.partial = msg.Text[m[6]:m[7]] == "P"
+	msg.reassemble = true
+	msg.Text = msg.Text[m[1]:]
+
+	// the metadata of partial lines is parsed once they are complete
+	if !msg.partial {
+		parseMetadata(msg, []byte(msg.Text), config)
+	}
+	return true
+}
@@ -0,0 +1,178 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDockerTag(t *testing.T) {
+	cases := []struct {
+		raw         string
+		application string
+		metadata    map[string]any
+	}{
+		{
+			raw:         "<30>Jan 18 11:07:53 node1 docker/0123456789ab[812]: listening on :8080",
+			application: "docker",
+			metadata:    map[string]any{"container.id": "0123456789ab"},
+		},
+		{
+			raw:         "<30>Jan 18 11:07:53 node1 docker/abc123[812]: listening on :8080",
+			application: "docker",
+			metadata:    map[string]any{"container.id": "abc123"},
+		},
+		{
+			raw:         "<30>Jan 18 11:07:53 node1 0123456789ab[812]: listening on :8080",
+			application: "docker",
+			metadata:    map[string]any{"container.id": "0123456789ab"},
+		},
+		{
+			raw:         "<30>Jan 18 11:07:53 node1 api/0123456789ab[812]: listening on :8080",
+			application: "api",
+			metadata:    map[string]any{"container.id": "0123456789ab", "container.name": "api"},
+		},
+		{
+			raw:         "<30>1 2024-01-18T11:07:53Z node1 nginx:1.25/web/0123456789ab 812 - - listening on :80",
+			application: "web",
+			metadata:    map[string]any{"container.id": "0123456789ab", "container.name": "web", "container.image": "nginx:1.25"},
+		},
+		{
+			raw:         "<30>Jan 18 11:07:53 node1 docker/worker[812]: started",
+			application: "worker",
+			metadata:    map[string]any{"container.name": "worker"},
+		},
+		{
+			raw:         "<30>Jan 18 11:07:53 node1 ctld/snmpd[812]: started",
+			application: "ctld/snmpd",
+			metadata:    map[string]any{},
+		},
+	}
+
+	for _, c := range cases {
+		msg := ParseLineWithFallback([]byte(c.raw), "10.1.1.1")
+		require.NotNil(t, msg, c.raw)
+		assert.Equal(t, c.application, msg.Application, c.raw)
+		assert.Equal(t, int64(812), msg.PID, c.raw)
+		assert.Equal(t, c.metadata, msg.Metadata, c.raw)
+	}
+}
+
+func TestParseCRI(t *testing.T) {
+	msg := ParseLineWithFallback([]byte(`<14>Jan 18 11:07:53 node1 kubelet: 2024-01-18T11:07:52.123456789Z stderr F level=warn msg="disk almost full"`), "10.1.1.1")
+	require.NotNil(t, msg)
+	assert.Equal(t, "node1", msg.Hostname)
+	assert.Equal(t, "kubelet", msg.Application)
+	assert.Equal(t, `level=warn msg="disk almost full"`, msg.Text)
+	assert.Equal(t, time.Date(2024, 1, 18, 11, 7, 52, 123456789, time.UTC).UnixNano(), msg.Timestamp)
+	assert.Equal(t, map[string]any{"stream": "stderr", "level": "warn", "msg": "disk almost full"}, msg.Metadata)
+
+	// without a syslog header
+	msg = ParseLineWithFallback([]byte(`2024-01-18T11:07:52.5Z stdout F {"msg":"hello"}`), "10.1.1.1")
+	require.NotNil(t, msg)
+	assert.Equal(t, "hello", msg.Text)
+	assert.Equal(t, "stdout", msg.Metadata["stream"])
+}
+
+func TestReassembleCRI(t *testing.T) {
+	var logs []*Log
+	p, err := NewWithConfig(func(msg *Log) { logs = append(logs, msg) }, &Config{MaxPartialSize: 64})
+	require.NoError(t, err)
+
+	const header = "<14>Jan 18 11:07:53 node1 app: "
+	p.WriteLine([]byte(header+"2024-01-18T11:07:52Z stdout P level=info msg=\"a long "), "10.1.1.1")
+	p.WriteLine([]byte(header+"2024-01-18T11:07:52Z stderr F other stream"), "10.1.1.1")
+	p.WriteLine([]byte(header+"2024-01-18T11:07:52Z stdout P line that was "), "10.1.1.1")
+	p.WriteLine([]byte(header+"2024-01-18T11:07:53Z stdout F split\" user=bob"), "10.1.1.1")
+
+	require.Len(t, logs, 2)
+	assert.Equal(t, "other stream", logs[0].Text)
+	assert.Equal(t, `level=info msg="a long line that was split" user=bob`, logs[1].Text)
+	assert.Equal(t, time.Date(2024, 1, 18, 11, 7, 52, 0, time.UTC).UnixNano(), logs[1].Timestamp)
+	assert.Equal(t, map[string]any{"stream": "stdout", "level": "info", "msg": "a long line that was split", "user": "bob"}, logs[1].Metadata)
+
+	// messages exceeding the maximum size are passed on as far as they got
+	logs = nil
+	p.WriteLine([]byte(header+"2024-01-18T11:07:52Z stdout P 0123456789012345678901234567890123456789"), "10.1.1.1")
+	p.WriteLine([]byte(header+"2024-01-18T11:07:52Z stdout P 0123456789012345678901234567890123456789"), "10.1.1.1")
+	require.Len(t, logs, 1)
+	assert.Len(t, logs[0].Text, 80)
+
+	// and so are the ones that are still pending on stop
+	logs = nil
+	p.WriteLine([]byte(header+"2024-01-18T11:07:52Z stdout P pending"), "10.1.1.1")
+	require.NoError(t, p.Flush())
+	assert.Empty(t, logs)
+	require.NoError(t, p.Stop())
+	require.Len(t, logs, 1)
+	assert.Equal(t, "pending", logs[0].Text)
+}
+
+func TestReassembleCRIRaw(t *testing.T) {
+	lines := []string{
+		"<14>Jan 18 11:07:53 node1 app: 2024-01-18T11:07:52Z stdout P first ",
+		"<14>Jan 18 11:07:53 node1 app: 2024-01-18T11:07:52Z stdout P second ",
+		"<14>Jan 18 11:07:53 node1 app: 2024-01-18T11:07:53Z stdout F thïrd",
+	}
+	joined := strings.Join(lines, "\n")
+	cut := strings.Index(joined, "ï")
+
+	var logs []*Log
+	// up to the middle of the `ï`
+	p, err := NewWithConfig(func(msg *Log) { logs = append(logs, msg) }, &Config{KeepRaw: true, MaxRawSize: cut + 1})
+	require.NoError(t, err)
+
+	for _, line := range lines {
+		p.WriteLine([]byte(line), "10.1.1.1")
+	}
+	require.Len(t, logs, 1)
+	assert.Equal(t, "first second thïrd", logs[0].Text)
+	assert.Equal(t, joined[:cut], logs[0].Raw)
+}
+
+func TestReassembleCRITimeout(t *testing.T) {
+	var logs []*Log
+	p, err := NewWithConfig(func(msg *Log) { logs = append(logs, msg) }, &Config{PartialTimeout: Duration(time.Nanosecond)})
+	require.NoError(t, err)
+
+	p.WriteLine([]byte("<14>Jan 18 11:07:53 node1 app: 2024-01-18T11:07:52Z stdout P never finished"), "10.1.1.1")
+	assert.Empty(t, logs)
+
+	time.Sleep(time.Millisecond)
+	require.NoError(t, p.Flush())
+	require.Len(t, logs, 1)
+	assert.Equal(t, "never finished", logs[0].Text)
+}
+
+func TestReassembleCRIMaxPending(t *testing.T) {
+	var logs []*Log
+	p, err := NewWithConfig(func(msg *Log) { logs = append(logs, msg) }, &Config{MaxPendingPartials: 2})
+	require.NoError(t, err)
+
+	write := func(host, text string) {
+		p.WriteLine([]byte("<14>Jan 18 11:07:53 "+host+" app: 2024-01-18T11:07:52Z stdout "+text), "10.1.1.1")
+	}
+	write("node1", "P one")
+	write("node2", "P two")
+	assert.Empty(t, logs)
+
+	// the message continued the longest time ago makes room
+	write("node3", "P three")
+	require.Len(t, logs, 1)
+	assert.Equal(t, "one", logs[0].Text)
+
+	write("node2", "P  more")
+	write("node4", "P four")
+	require.Len(t, logs, 2)
+	assert.Equal(t, "three", logs[1].Text)
+
+	write("node2", "F  done")
+	require.Len(t, logs, 3)
+	assert.Equal(t, "two more done", logs[2].Text)
+
+	_, err = NewWithConfig(func(*Log) {}, &Config{MaxPendingPartials: -1})
+	assert.Error(t, err)
+}
@@ -0,0 +1,152 @@
+package parser
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fortinetFieldPrefix namespaces the Fortinet fields in Log.Metadata.
+const fortinetFieldPrefix = "fortinet."
+
+var (
+	fortinetPrefix = []byte("date=")
+	// fortinetDeviceKeys identify the sending device in every message.
+	fortinetDeviceKeys = [][]byte{[]byte("devname="), []byte("devid="), []byte("device_id=")}
+)
+
+// isFortinet reports whether data is a FortiGate or FortiWeb message, which
+// starts with the date and names the device it was sent by.
+func isFortinet(data []byte) bool {
+	if !bytes.HasPrefix(data, fortinetPrefix) {
+		return false
+	}
+	for _, key := range fortinetDeviceKeys {
+		if bytes.Contains(data, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseFortinet parses the `key=value` messages of FortiGate and FortiWeb:
+//
+//	date=2024-01-18 time=11:07:53 devname="FG100E" devid="FG100E4Q17000000" tz="+0100" logid="0000000013" type="traffic" level="notice" srcip=10.0.0.1 ...
+//
+// All fields are kept. Quoted values are strings, so IDs with leading zeros
+// stay intact, bare values are typed like logfmt.
+func parseFortinet(msg *Log) bool {
+	text := strings.TrimSpace(msg.Text)
+	if !isFortinet([]byte(text)) {
+		return false
+	}
+
+	fields := map[string]string{}
+	scanLogfmt(text, func(pair logfmtPair) {
+		if pair.key == "" {
+			return
+		}
+
+		fields[pair.key] = pair.value
+		if pair.quoted {
+			msg.Metadata[fortinetFieldPrefix+pair.key] = pair.value
+		} else {
+			msg.Metadata[fortinetFieldPrefix+pair.key] = logfmtValue(pair.value)
+		}
+	})
+
+	parseFortinetTime(msg, fields)
+
+	if devname := fields["devname"]; devname != "" {
+		msg.Hostname = devname
+	}
+
+	// FortiGate logs the `level`, FortiWeb the `pri`
+	for _, key := range []string{"level", "pri"} {
+		if severity := fortinetSeverity(fields[key]); severity != Unknown {
+			msg.Severity = severity
+			break
+		}
+	}
+
+	if msg.Application == "" || msg.Application == syntheticApplication {
+		msg.Application = "fortigate"
+		if _, ok := fields["log_id"]; ok {
+			msg.Application = "fortiweb"
+		}
+	}
+	if message := fields["msg"]; message != "" {
+		msg.Text = message
+	}
+
+	return true
+}
+
+// parseFortinetTime sets the timestamp of msg from the date and time fields,
+// in the time zone given by either `tz` (FortiGate) or `timezone` (FortiWeb).
+// Without one the time zone configured for the sender applies.
+func parseFortinetTime(msg *Log, fields map[string]string) {
+	ts, err := time.Parse("2006-01-02 15:04:05", fields["date"]+" "+fields["time"])
+	if err != nil {
+		return
+	}
+
+	offset, ok := fortinetZoneOffset(fields["tz"])
+	if !ok {
+		offset, ok = fortinetZoneOffset(fields["timezone"])
+	}
+
+	if ok {
+		ts = ts.Add(-time.Duration(offset) * time.Second)
+	} else {
+		msg.wallClock = ts
+		msg.hasWallClock = true
+		ts = fromWallClock(ts, time.Local, time.Now())
+	}
+	msg.Timestamp = ts.UnixNano()
+}
+
+// fortinetZoneOffset parses a UTC offset in seconds, either like `+0100` and
+// `-05:00`, or like `(GMT+1:00)Brussels,Copenhagen,Madrid,Paris`.
+func fortinetZoneOffset(tz string) (int, bool) {
+	if rest, ok := strings.CutPrefix(tz, "(GMT"); ok {
+		tz, _, _ = strings.Cut(rest, ")")
+		if tz == "" {
+			return 0, true
+		}
+	}
+
+	if len(tz) < 2 || (tz[0] != '+' && tz[0] != '-') {
+		return 0, false
+	}
+
+	hours, minutes, ok := strings.Cut(tz[1:], ":")
+	if !ok && len(hours) == 4 {
+		hours, minutes = hours[:2], hours[2:]
+	}
+
+	h, err := strconv.Atoi(hours)
+	if err != nil || h > 14 {
+		return 0, false
+	}
+	var m int
+	if minutes != "" {
+		if m, err = strconv.Atoi(minutes); err != nil || m > 59 {
+			return 0, false
+		}
+	}
+
+	offset := h*3600 + m*60
+	if tz[0] == '-' {
+		offset = -offset
+	}
+	return offset, true
+}
+
+func fortinetSeverity(level string) int64 {
+	if level == "information" {
+		return Info
+	}
+	return int64(SeverityFromString(level))
+}
@@ -0,0 +1,139 @@
+package parser
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFortinet(t *testing.T) {
+	cases := []struct {
+		raw         string
+		hostname    string
+		application string
+		text        string
+		severity    int64
+		time        time.Time
+		metadata    map[string]any
+	}{
+		{
+			raw:         `<189>date=2024-01-18 time=11:07:53 devname="FG100E" devid="FG100E4Q17000000" tz="+0100" logid="0000000013" type="traffic" subtype="forward" level="notice" vd="root" srcip=10.0.0.1 srcport=51234 dstip=198.51.100.7 dstport=443 action="close" sentbyte=1024 duration=12 policyname="LAN to WAN"`,
+			hostname:    "FG100E",
+			application: "fortigate",
+			severity:    Info,
+			time:        time.Date(2024, 1, 18, 10, 7, 53, 0, time.UTC),
+			metadata: map[string]any{
+				"fortinet.date":       "2024-01-18",
+				"fortinet.time":       "11:07:53",
+				"fortinet.devname":    "FG100E",
+				"fortinet.devid":      "FG100E4Q17000000",
+				"fortinet.tz":         "+0100",
+				"fortinet.logid":      "0000000013",
+				"fortinet.type":       "traffic",
+				"fortinet.subtype":    "forward",
+				"fortinet.level":      "notice",
+				"fortinet.vd":         "root",
+				"fortinet.srcip":      "10.0.0.1",
+				"fortinet.srcport":    int64(51234),
+				"fortinet.dstip":      "198.51.100.7",
+				"fortinet.dstport":    int64(443),
+				"fortinet.action":     "close",
+				"fortinet.sentbyte":   int64(1024),
+				"fortinet.duration":   int64(12),
+				"fortinet.policyname": "LAN to WAN",
+			},
+		},
+		{
+			raw:         `date=2024-01-18 time=11:07:53 devname="FW01" devid="FG200F" tz="-05:00" logid="0100032001" type="event" subtype="system" level="information" user="admin" msg="Administrator admin logged in successfully from https(10.0.0.2)"`,
+			hostname:    "FW01",
+			application: "fortigate",
+			text:        "Administrator admin logged in successfully from https(10.0.0.2)",
+			severity:    Info,
+			time:        time.Date(2024, 1, 18, 16, 7, 53, 0, time.UTC),
+			metadata: map[string]any{
+				"fortinet.date":    "2024-01-18",
+				"fortinet.time":    "11:07:53",
+				"fortinet.devname": "FW01",
+				"fortinet.devid":   "FG200F",
+				"fortinet.tz":      "-05:00",
+				"fortinet.logid":   "0100032001",
+				"fortinet.type":    "event",
+				"fortinet.subtype": "system",
+				"fortinet.level":   "information",
+				"fortinet.user":    "admin",
+				"fortinet.msg":     "Administrator admin logged in successfully from https(10.0.0.2)",
+			},
+		},
+		{
+			raw:         `<185>Jan 18 11:07:53 fortiweb date=2024-01-18 time=11:07:53 log_id=20000010 device_id=FV-1KD3A14800059 timezone="(GMT+1:00)Brussels,Copenhagen,Madrid,Paris" type=attack pri=alert msg="SQL injection"`,
+			hostname:    "fortiweb",
+			application: "fortiweb",
+			text:        "SQL injection",
+			severity:    Error,
+			time:        time.Date(2024, 1, 18, 10, 7, 53, 0, time.UTC),
+			metadata: map[string]any{
+				"fortinet.date":      "2024-01-18",
+				"fortinet.time":      "11:07:53",
+				"fortinet.log_id":    int64(20000010),
+				"fortinet.device_id": "FV-1KD3A14800059",
+				"fortinet.timezone":  "(GMT+1:00)Brussels,Copenhagen,Madrid,Paris",
+				"fortinet.type":      "attack",
+				"fortinet.pri":       "alert",
+				"fortinet.msg":       "SQL injection",
+			},
+		},
+		{
+			// without a time zone the one configured for the sender applies
+			raw:         `date=2024-01-18 time=11:07:53 devname="FG" logid="0000000013" level="warning"`,
+			hostname:    "FG",
+			application: "fortigate",
+			severity:    Warning,
+			time:        time.Date(2024, 1, 18, 11, 7, 53, 0, time.Local),
+			metadata: map[string]any{
+				"fortinet.date":    "2024-01-18",
+				"fortinet.time":    "11:07:53",
+				"fortinet.devname": "FG",
+				"fortinet.logid":   "0000000013",
+				"fortinet.level":   "warning",
+			},
+		},
+	}
+
+	for _, c := range cases {
+		msg := ParseLineWithFallback([]byte(c.raw), "10.1.1.1")
+		require.NotNil(t, msg, c.raw)
+
+		assert.Equal(t, c.hostname, msg.Hostname, c.raw)
+		assert.Equal(t, c.application, msg.Application, c.raw)
+		if c.text != "" {
+			assert.Equal(t, c.text, msg.Text, c.raw)
+		}
+		assert.Equal(t, c.time.UnixNano(), msg.Timestamp, c.raw)
+		assert.Equal(t, c.severity, msg.Severity, c.raw)
+		assert.Equal(t, c.metadata, msg.Metadata, c.raw)
+	}
+}
+
+func TestFortinetZoneOffset(t *testing.T) {
+	var testData = map[string]int{
+		"+0100":                  3600,
+		"-05:00":                 -5 * 3600,
+		"+0530":                  5*3600 + 30*60,
+		"(GMT+1:00)Brussels":     3600,
+		"(GMT-3:30)Newfoundland": -(3*3600 + 30*60),
+		"(GMT)Casablanca":        0,
+	}
+
+	for tz, offset := range testData {
+		actual, ok := fortinetZoneOffset(tz)
+		assert.True(t, ok, tz)
+		assert.Equal(t, offset, actual, tz)
+	}
+
+	for _, tz := range []string{"", "CET", "+25:00", "(GMT+x)"} {
+		_, ok := fortinetZoneOffset(tz)
+		assert.False(t, ok, tz)
+	}
+}
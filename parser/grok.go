@@ -0,0 +1,375 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"maps"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// grokFailureKey marks the messages a Grok rule applied to, but none of
+	// its patterns matched.
+	grokFailureKey = "grok.failure"
+	// grokGroupPrefix starts the names of the groups Grok references with a
+	// field are compiled to.
+	grokGroupPrefix = "grok_"
+	// maxGrokDepth bounds the nesting of pattern references.
+	maxGrokDepth = 32
+)
+
+var (
+	// grokReferenceRegex matches `%{NAME}`, `%{NAME:field}` and
+	// `%{NAME:field:type}`.
+	grokReferenceRegex = regexp.MustCompile(`%\{(\w+)(?::([\w.@-]+))?(?::(int|float|string))?\}`)
+
+	// grokCache holds the compiled expressions, shared by all configs.
+	grokCache sync.Map
+
+	// grokPatterns is the standard pattern library of Logstash, rewritten for
+	// the RE2 syntax without lookarounds and atomic groups.
+	grokPatterns = map[string]string{
+		"USERNAME":       `[a-zA-Z0-9._-]+`,
+		"USER":           `%{USERNAME}`,
+		"EMAILLOCALPART": `[a-zA-Z0-9!#$%&'*+/=?^_{|}~-]+(?:\.[a-zA-Z0-9!#$%&'*+/=?^_{|}~-]+)*`,
+		"EMAILADDRESS":   `%{EMAILLOCALPART}@%{HOSTNAME}`,
+		"HTTPDUSER":      `%{EMAILADDRESS}|%{USER}`,
+		"INT":            `[+-]?[0-9]+`,
+		"BASE10NUM":      `[+-]?(?:[0-9]+(?:\.[0-9]+)?|\.[0-9]+)`,
+		"NUMBER":         `%{BASE10NUM}`,
+		"BASE16NUM":      `[+-]?(?:0x)?[0-9A-Fa-f]+`,
+		"POSINT":         `\b[1-9][0-9]*\b`,
+		"NONNEGINT":      `\b[0-9]+\b`,
+		"WORD":           `\b\w+\b`,
+		"NOTSPACE":       `\S+`,
+		"SPACE":          `\s*`,
+		"DATA":           `.*?`,
+		"GREEDYDATA":     `.*`,
+		"QUOTEDSTRING":   `"(?:[^"\\]|\\.)*"|'(?:[^'\\]|\\.)*'`,
+		"QS":             `%{QUOTEDSTRING}`,
+		"UUID":           `[A-Fa-f0-9]{8}-(?:[A-Fa-f0-9]{4}-){3}[A-Fa-f0-9]{12}`,
+
+		"CISCOMAC":   `(?:[A-Fa-f0-9]{4}\.){2}[A-Fa-f0-9]{4}`,
+		"WINDOWSMAC": `(?:[A-Fa-f0-9]{2}-){5}[A-Fa-f0-9]{2}`,
+		"COMMONMAC":  `(?:[A-Fa-f0-9]{2}:){5}[A-Fa-f0-9]{2}`,
+		"MAC":        `%{CISCOMAC}|%{WINDOWSMAC}|%{COMMONMAC}`,
+		"IPV4":       `(?:(?:25[0-5]|2[0-4][0-9]|1[0-9]{2}|[1-9]?[0-9])\.){3}(?:25[0-5]|2[0-4][0-9]|1[0-9]{2}|[1-9]?[0-9])`,
+		"IPV6": `(?:[0-9A-Fa-f]{1,4}:){6}%{IPV4}|::(?:[Ff]{4}(?::0{1,4})?:)?%{IPV4}|` +
+			`(?:[0-9A-Fa-f]{1,4}:){7}[0-9A-Fa-f]{1,4}|` +
+			`[0-9A-Fa-f]{1,4}:(?::[0-9A-Fa-f]{1,4}){1,6}|` +
+			`(?:[0-9A-Fa-f]{1,4}:){1,2}(?::[0-9A-Fa-f]{1,4}){1,5}|` +
+			`(?:[0-9A-Fa-f]{1,4}:){1,3}(?::[0-9A-Fa-f]{1,4}){1,4}|` +
+			`(?:[0-9A-Fa-f]{1,4}:){1,4}(?::[0-9A-Fa-f]{1,4}){1,3}|` +
+			`(?:[0-9A-Fa-f]{1,4}:){1,5}(?::[0-9A-Fa-f]{1,4}){1,2}|` +
+			`(?:[0-9A-Fa-f]{1,4}:){1,6}:[0-9A-Fa-f]{1,4}|` +
+			`:(?:(?::[0-9A-Fa-f]{1,4}){1,7}|:)|` +
+			`(?:[0-9A-Fa-f]{1,4}:){1,7}:`,
+		"IP":           `%{IPV6}|%{IPV4}`,
+		"HOSTNAME":     `\b[0-9A-Za-z][0-9A-Za-z-]{0,62}(?:\.[0-9A-Za-z][0-9A-Za-z-]{0,62})*\.?`,
+		"IPORHOST":     `%{IP}|%{HOSTNAME}`,
+		"HOSTPORT":     `%{IPORHOST}:%{POSINT}`,
+		"UNIXPATH":     `(?:/[\w%!$@:.,+~-]*)+`,
+		"WINPATH":      `(?:[A-Za-z]+:|\\)(?:\\[^\\?*]*)+`,
+		"PATH":         `%{UNIXPATH}|%{WINPATH}`,
+		"TTY":          `/dev/(?:pts|tty[pq]?)(?:\w+)?/?[0-9]+`,
+		"URIPROTO":     `[A-Za-z][A-Za-z0-9+.-]*`,
+		"URIHOST":      `%{IPORHOST}(?::%{POSINT})?`,
+		"URIPATH":      `(?:/[A-Za-z0-9$.+!*'(){},~:;=@#%&_-]*)+`,
+		"URIPARAM":     `\?[A-Za-z0-9$.+!*'|(){},~@#%&/=:;_?\[\]<>-]*`,
+		"URIPATHPARAM": `%{URIPATH}(?:%{URIPARAM})?`,
+		"URI":          `%{URIPROTO}://(?:%{USER}(?::[^@]*)?@)?(?:%{URIHOST})?(?:%{URIPATHPARAM})?`,
+
+		"MONTH":             `\b(?:[Jj]an(?:uary)?|[Ff]eb(?:ruary)?|[Mm]ar(?:ch)?|[Aa]pr(?:il)?|[Mm]ay|[Jj]un(?:e)?|[Jj]ul(?:y)?|[Aa]ug(?:ust)?|[Ss]ep(?:tember)?|[Oo]ct(?:ober)?|[Nn]ov(?:ember)?|[Dd]ec(?:ember)?)\b`,
+		"MONTHNUM":          `1[0-2]|0?[1-9]`,
+		"MONTHNUM2":         `0[1-9]|1[0-2]`,
+		"MONTHDAY":          `3[01]|[12][0-9]|0?[1-9]`,
+		"DAY":               `\b(?:Mon(?:day)?|Tue(?:sday)?|Wed(?:nesday)?|Thu(?:rsday)?|Fri(?:day)?|Sat(?:urday)?|Sun(?:day)?)\b`,
+		"YEAR":              `(?:\d\d){1,2}`,
+		"HOUR":              `2[0123]|[01]?[0-9]`,
+		"MINUTE":            `[0-5][0-9]`,
+		"SECOND":            `(?:60|[0-5]?[0-9])(?:[:.,][0-9]+)?`,
+		"TIME":              `%{HOUR}:%{MINUTE}:%{SECOND}`,
+		"DATE_US":           `%{MONTHNUM}[/-]%{MONTHDAY}[/-]%{YEAR}`,
+		"DATE_EU":           `%{MONTHDAY}[./-]%{MONTHNUM}[./-]%{YEAR}`,
+		"DATE":              `%{DATE_US}|%{DATE_EU}`,
+		"DATESTAMP":         `%{DATE}[- ]%{TIME}`,
+		"TZ":                `[A-Z]{3}`,
+		"ISO8601_TIMEZONE":  `Z|[+-]%{HOUR}(?::?%{MINUTE})`,
+		"ISO8601_SECOND":    `%{SECOND}`,
+		"TIMESTAMP_ISO8601": `%{YEAR}-%{MONTHNUM}-%{MONTHDAY}[T ]%{HOUR}:?%{MINUTE}(?::?%{SECOND})?(?:%{ISO8601_TIMEZONE})?`,
+		"DATESTAMP_RFC822":  `%{DAY} %{MONTH} %{MONTHDAY} %{YEAR} %{TIME} %{TZ}`,
+		"DATESTAMP_RFC2822": `%{DAY}, %{MONTHDAY} %{MONTH} %{YEAR} %{TIME} %{ISO8601_TIMEZONE}`,
+		"DATESTAMP_OTHER":   `%{DAY} %{MONTH} %{MONTHDAY} %{TIME} %{TZ} %{YEAR}`,
+		"HTTPDATE":          `%{MONTHDAY}/%{MONTH}/%{YEAR}:%{TIME} %{INT}`,
+		"SYSLOGTIMESTAMP":   `%{MONTH} +%{MONTHDAY} %{TIME}`,
+
+		"PROG":           `[\x21-\x5a\x5c\x5e-\x7e]+`,
+		"SYSLOGPROG":     `%{PROG:program}(?:\[%{POSINT:pid}\])?`,
+		"SYSLOGHOST":     `%{IPORHOST}`,
+		"SYSLOGFACILITY": `<%{NONNEGINT:facility}.%{NONNEGINT:priority}>`,
+		"LOGLEVEL": `[Aa]lert|ALERT|[Tt]race|TRACE|[Dd]ebug|DEBUG|[Nn]otice|NOTICE|[Ii]nfo(?:rmation)?|INFO(?:RMATION)?|` +
+			`[Ww]arn(?:ing)?|WARN(?:ING)?|[Ee]rr(?:or)?|ERR(?:OR)?|[Cc]rit(?:ical)?|CRIT(?:ICAL)?|[Ff]atal|FATAL|` +
+			`[Ss]evere|SEVERE|EMERG(?:ENCY)?|[Ee]merg(?:ency)?`,
+
+		"COMMONAPACHELOG": `%{IPORHOST:clientip} %{HTTPDUSER:ident} %{USER:auth} \[%{HTTPDATE:timestamp}\] ` +
+			`"(?:%{WORD:verb} %{NOTSPACE:request}(?: HTTP/%{NUMBER:httpversion})?|%{DATA:rawrequest})" ` +
+			`%{NUMBER:response:int} (?:%{NUMBER:bytes:int}|-)`,
+		"COMBINEDAPACHELOG": `%{COMMONAPACHELOG} %{QS:referrer} %{QS:agent}`,
+	}
+)
+
+// GrokRule parses the messages of matching applications and hosts with Grok
+// expressions like `%{IP:client} %{WORD:method} %{NUMBER:bytes:int}`. The
+// named captures become metadata fields, typed if the reference says so.
+type GrokRule struct {
+	// Application matches the application of a message and may contain `*`
+	// wildcards.
+	Application string `json:"application,omitempty"`
+	// Hostname matches the hostname of a message and may contain `*`
+	// wildcards.
+	Hostname string `json:"hostname,omitempty"`
+	// Patterns are the expressions that are tried in order, the first to
+	// match wins.
+	Patterns []string `json:"patterns"`
+}
+
+type grokRule struct {
+	application string
+	hostname    string
+	patterns    []*grokPattern
+}
+
+func (r *grokRule) matches(msg *Log) bool {
+	if r.application != "" {
+		if ok, _ := path.Match(r.application, msg.Application); !ok {
+			return false
+		}
+	}
+	if r.hostname != "" {
+		if ok, _ := path.Match(r.hostname, strings.ToLower(msg.Hostname)); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// grokField is the field a group of a compiled expression is stored in.
+type grokField struct {
+	name string
+	kind string
+}
+
+// grokPattern is a compiled Grok expression.
+type grokPattern struct {
+	regex  *regexp.Regexp
+	fields []grokField
+}
+
+// compileGrokRules compiles the rules with the standard patterns and those
+// of the given files.
+func compileGrokRules(rules []GrokRule, files []string) ([]grokRule, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+
+	patterns := grokPatterns
+	if len(files) > 0 {
+		patterns = maps.Clone(grokPatterns)
+		for _, file := range files {
+			if err := readGrokPatterns(file, patterns); err != nil {
+				return nil, fmt.Errorf("grok pattern file %q: %w", file, err)
+			}
+		}
+	}
+
+	compiled := make([]grokRule, 0, len(rules))
+	for i, rule := range rules {
+		if rule.Application == "" && rule.Hostname == "" {
+			return nil, fmt.Errorf("grok rule %d: rule must set application, hostname or both", i)
+		}
+		if len(rule.Patterns) == 0 {
+			return nil, fmt.Errorf("grok rule %d: rule has no patterns", i)
+		}
+
+		r := grokRule{application: rule.Application, hostname: strings.ToLower(rule.Hostname)}
+		for _, glob := range []string{r.application, r.hostname} {
+			if _, err := path.Match(glob, ""); err != nil {
+				return nil, fmt.Errorf("grok rule %d: %q: %w", i, glob, err)
+			}
+		}
+		for _, expr := range rule.Patterns {
+			p, err := compileGrok(expr, patterns)
+			if err != nil {
+				return nil, fmt.Errorf("grok rule %d: %w", i, err)
+			}
+			r.patterns = append(r.patterns, p)
+		}
+		compiled = append(compiled, r)
+	}
+	return compiled, nil
+}
+
+// readGrokPatterns adds the patterns of a file, one `NAME pattern` per line,
+// to patterns. Empty lines and those starting with `#` are skipped.
+func readGrokPatterns(file string, patterns map[string]string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		name, pattern, ok := strings.Cut(line, " ")
+		if !ok || strings.TrimSpace(pattern) == "" {
+			return fmt.Errorf("line %d: expected a name and a pattern", n)
+		}
+		patterns[name] = strings.TrimSpace(pattern)
+	}
+	return scanner.Err()
+}
+
+// compileGrok compiles a Grok expression. Compiled expressions are cached,
+// so that the rules of several configs share them.
+func compileGrok(expr string, patterns map[string]string) (*grokPattern, error) {
+	var fields []grokField
+	source, err := expandGrok(expr, patterns, &fields, nil)
+	if err != nil {
+		return nil, fmt.Errorf("pattern %q: %w", expr, err)
+	}
+
+	regex, ok := grokCache.Load(source)
+	if !ok {
+		compiled, err := regexp.Compile(source)
+		if err != nil {
+			return nil, fmt.Errorf("pattern %q: %w", expr, err)
+		}
+		regex, _ = grokCache.LoadOrStore(source, compiled)
+	}
+
+	p := &grokPattern{regex: regex.(*regexp.Regexp)}
+	p.fields = make([]grokField, len(p.regex.SubexpNames()))
+	for i, name := range p.regex.SubexpNames() {
+		if n, ok := strings.CutPrefix(name, grokGroupPrefix); ok {
+			index, _ := strconv.Atoi(n)
+			p.fields[i] = fields[index]
+		} else if name != "" {
+			// a named group of the pattern itself
+			p.fields[i] = grokField{name: name}
+		}
+	}
+	return p, nil
+}
+
+// expandGrok replaces the references in expr by the patterns they refer to,
+// wrapped in groups. References with a field become named groups, whose
+// fields are appended to fields.
+func expandGrok(expr string, patterns map[string]string, fields *[]grokField, stack []string) (string, error) {
+	if len(stack) > maxGrokDepth {
+		return "", fmt.Errorf("references nested deeper than %d", maxGrokDepth)
+	}
+
+	var (
+		expanded strings.Builder
+		last     int
+	)
+	for _, loc := range grokReferenceRegex.FindAllStringSubmatchIndex(expr, -1) {
+		expanded.WriteString(expr[last:loc[0]])
+		last = loc[1]
+
+		name := expr[loc[2]:loc[3]]
+		pattern, ok := patterns[name]
+		if !ok {
+			return "", fmt.Errorf("unknown pattern %q", name)
+		}
+		if slices.Contains(stack, name) {
+			return "", fmt.Errorf("pattern %q refers to itself", name)
+		}
+
+		inner, err := expandGrok(pattern, patterns, fields, append(stack, name))
+		if err != nil {
+			return "", err
+		}
+
+		if loc[4] < 0 {
+			expanded.WriteString("(?:" + inner + ")")
+			continue
+		}
+
+		field := grokField{name: expr[loc[4]:loc[5]], kind: "string"}
+		if loc[6] >= 0 {
+			field.kind = expr[loc[6]:loc[7]]
+		}
+		fmt.Fprintf(&expanded, "(?P<%s%d>%s)", grokGroupPrefix, len(*fields), inner)
+		*fields = append(*fields, field)
+	}
+	expanded.WriteString(expr[last:])
+
+	return expanded.String(), nil
+}
+
+// parseGrok parses msg with the first pattern of the matching Grok rules
+// that matches. Messages that none of them match are marked, and left to the
+// other parsers.
+func parseGrok(msg *Log, config *Config) bool {
+	var applied bool
+	for i := range config.grokRules {
+		rule := &config.grokRules[i]
+		if !rule.matches(msg) {
+			continue
+		}
+		applied = true
+
+		for _, p := range rule.patterns {
+			if p.parse(msg) {
+				return true
+			}
+		}
+	}
+
+	if applied {
+		msg.Metadata[grokFailureKey] = true
+	}
+	return false
+}
+
+func (p *grokPattern) parse(msg *Log) bool {
+	values := p.regex.FindStringSubmatch(msg.Text)
+	if values == nil {
+		return false
+	}
+
+	for i, field := range p.fields {
+		if field.name == "" || values[i] == "" {
+			continue
+		}
+		msg.Metadata[field.name] = field.value(values[i])
+	}
+	return true
+}
+
+// value types a captured value, keeping it as a string if it doesn't parse.
+func (f grokField) value(value string) any {
+	switch f.kind {
+	case "int":
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return n
+		}
+	case "float":
+		if n, err := strconv.ParseFloat(value, 64); err == nil {
+			return n
+		}
+	}
+	return value
+}
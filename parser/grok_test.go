@@ -0,0 +1,152 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGrokPatterns(t *testing.T) {
+	cases := []struct {
+		pattern string
+		match   []string
+		noMatch []string
+	}{
+		{"IPV4", []string{"10.0.0.1", "255.255.255.255"}, []string{"10.0.0", "host"}},
+		{"IPV6", []string{"2001:db8::1", "::1", "fe80::1:2:3", "::ffff:10.0.0.1", "2001:db8:0:0:0:0:0:1"}, []string{"10.0.0.1", "db8"}},
+		{"HOSTNAME", []string{"example.com", "web-01.corp.example.", "localhost"}, []string{"-bad"}},
+		{"NUMBER", []string{"42", "-1.5", ".5"}, []string{"abc"}},
+		{"TIMESTAMP_ISO8601", []string{"2024-01-18T11:07:53Z", "2024-01-18 11:07:53.123+01:00", "2024-01-18T11:07"}, []string{"18/Jan/2024"}},
+		{"HTTPDATE", []string{"18/Jan/2024:11:07:53 +0100"}, []string{"2024-01-18T11:07:53Z"}},
+		{"SYSLOGTIMESTAMP", []string{"Jan 18 11:07:53", "Jan  8 11:07:53"}, []string{"18 Jan 11:07:53"}},
+		{"MAC", []string{"00:11:22:33:44:55", "00-11-22-33-44-55", "0011.2233.4455"}, []string{"00:11:22"}},
+		{"UUID", []string{"123e4567-e89b-12d3-a456-426614174000"}, []string{"123e4567"}},
+		{"URI", []string{"https://user@example.com:8443/a/b?c=d"}, []string{"example.com"}},
+		{"LOGLEVEL", []string{"INFO", "warning", "Error"}, []string{"chatty"}},
+	}
+
+	for _, c := range cases {
+		p, err := compileGrok("^%{"+c.pattern+"}$", grokPatterns)
+		require.NoError(t, err, c.pattern)
+		for _, s := range c.match {
+			assert.True(t, p.regex.MatchString(s), "%s should match %q", c.pattern, s)
+		}
+		for _, s := range c.noMatch {
+			assert.False(t, p.regex.MatchString(s), "%s should not match %q", c.pattern, s)
+		}
+	}
+}
+
+func TestParseGrok(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "patterns")
+	require.NoError(t, os.WriteFile(file, []byte("# in-house formats\n\nORDERID ORD-[0-9]{6}\nCURRENCY [A-Z]{3}\n"), 0o600))
+
+	config := mustCompile(&Config{
+		GrokPatternFiles: []string{file},
+		GrokRules: []GrokRule{
+			{
+				Application: "billing*",
+				Patterns: []string{
+					`^%{ORDERID:order} charged %{NUMBER:amount:float} %{CURRENCY:currency} to %{EMAILADDRESS:customer}$`,
+					`^%{ORDERID:order} refunded$`,
+				},
+			},
+			{
+				Hostname: "web-*",
+				Patterns: []string{`^%{COMBINEDAPACHELOG}`},
+			},
+		},
+	})
+
+	msg := parseLineWithFallback([]byte("<14>Jan 18 11:07:53 host billing-v2: ORD-000042 charged 19.99 EUR to alice@example.com"), "10.1.1.1", config)
+	require.NotNil(t, msg)
+	assert.Equal(t, map[string]any{
+		"order":    "ORD-000042",
+		"amount":   19.99,
+		"currency": "EUR",
+		"customer": "alice@example.com",
+	}, msg.Metadata)
+
+	// the first matching pattern wins
+	msg = parseLineWithFallback([]byte("<14>Jan 18 11:07:53 host billing: ORD-000042 refunded"), "10.1.1.1", config)
+	require.NotNil(t, msg)
+	assert.Equal(t, map[string]any{"order": "ORD-000042"}, msg.Metadata)
+
+	// by hostname, with the standard library
+	msg = parseLineWithFallback([]byte(`<14>Jan 18 11:07:53 WEB-01 app: 10.0.0.1 - - [18/Jan/2024:11:07:52 +0100] "GET / HTTP/1.1" 200 612 "-" "curl/8.0"`), "10.1.1.1", config)
+	require.NotNil(t, msg)
+	assert.Equal(t, map[string]any{
+		"clientip":    "10.0.0.1",
+		"ident":       "-",
+		"auth":        "-",
+		"timestamp":   "18/Jan/2024:11:07:52 +0100",
+		"verb":        "GET",
+		"request":     "/",
+		"httpversion": "1.1",
+		"response":    int64(200),
+		"bytes":       int64(612),
+		"referrer":    `"-"`,
+		"agent":       `"curl/8.0"`,
+	}, msg.Metadata)
+
+	// failures are marked and passed on
+	msg = parseLineWithFallback([]byte("<14>Jan 18 11:07:53 host billing: something else entirely"), "10.1.1.1", config)
+	require.NotNil(t, msg)
+	assert.Equal(t, "something else entirely", msg.Text)
+	assert.Equal(t, map[string]any{"grok.failure": true}, msg.Metadata)
+
+	// and other messages are left alone
+	msg = parseLineWithFallback([]byte("<14>Jan 18 11:07:53 host app: hello"), "10.1.1.1", config)
+	require.NotNil(t, msg)
+	assert.Empty(t, msg.Metadata)
+}
+
+func TestCompileGrokErrors(t *testing.T) {
+	dir := t.TempDir()
+	loop := filepath.Join(dir, "loop")
+	require.NoError(t, os.WriteFile(loop, []byte("A %{B}\nB x%{A}\n"), 0o600))
+	invalid := filepath.Join(dir, "invalid")
+	require.NoError(t, os.WriteFile(invalid, []byte("NONAME\n"), 0o600))
+
+	for _, config := range []*Config{
+		{GrokRules: []GrokRule{{Patterns: []string{"%{IP}"}}}},
+		{GrokRules: []GrokRule{{Application: "app"}}},
+		{GrokRules: []GrokRule{{Application: "app", Patterns: []string{"%{NOPE}"}}}},
+		{GrokRules: []GrokRule{{Application: "app", Patterns: []string{"%{IP:ip} ("}}}},
+		{GrokRules: []GrokRule{{Application: "[", Patterns: []string{"%{IP}"}}}},
+		{GrokRules: []GrokRule{{Application: "app", Patterns: []string{"%{A}"}}}, GrokPatternFiles: []string{loop}},
+		{GrokRules: []GrokRule{{Application: "app", Patterns: []string{"%{IP}"}}}, GrokPatternFiles: []string{invalid}},
+		{GrokRules: []GrokRule{{Application: "app", Patterns: []string{"%{IP}"}}}, GrokPatternFiles: []string{filepath.Join(dir, "missing")}},
+	} {
+		_, err := NewWithConfig(func(*Log) {}, config)
+		assert.Error(t, err, config)
+	}
+}
+
+func BenchmarkGrok(b *testing.B) {
+	const raw = `<14>Jan 18 11:07:53 web-01 nginx: 10.0.0.1 - - [18/Jan/2024:11:07:52 +0100] "GET /index.html HTTP/1.1" 200 612 "-" "curl/8.0"`
+
+	// the built-in access log parser against the equivalent Grok rule
+	configs := []struct {
+		name   string
+		config *Config
+	}{
+		{"accesslog", &Config{}},
+		{"grok", &Config{GrokRules: []GrokRule{{Application: "nginx", Patterns: []string{`^%{COMBINEDAPACHELOG}`}}}}},
+	}
+	for _, c := range configs {
+		b.Run(c.name, func(b *testing.B) {
+			p, err := NewWithConfig(func(*Log) {}, c.config)
+			if err != nil {
+				b.Fatal(err)
+			}
+			for b.Loop() {
+				p.WriteLine([]byte(raw), "127.0.0.1")
+			}
+		})
+	}
+}
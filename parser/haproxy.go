@@ -0,0 +1,109 @@
+package parser
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// haproxyFieldPrefix namespaces the HAProxy fields in Log.Metadata.
+	haproxyFieldPrefix = "haproxy."
+
+	haproxyApplication = "haproxy"
+)
+
+// haproxyFormat is one of the log formats of HAProxy. Its fields name the
+// submatches of the regex, in order.
+type haproxyFormat struct {
+	regex  *regexp.Regexp
+	fields []string
+}
+
+var (
+	// haproxyFormats are the formats of `option httplog`, `option tcplog`
+	// and `option httplog clf`:
+	//
+	//	10.0.1.2:33317 [06/Feb/2009:12:14:14.655] http-in static/srv1 10/0/30/69/109 200 2750 - - ---- 1/1/1/1/0 0/0 {1wt.eu} {} "GET /index.html HTTP/1.1"
+	//	10.0.1.2:33313 [06/Feb/2009:12:12:51.443] fnt bck/srv1 0/0/5007 212 -- 0/0/0/0/3 0/0
+	//	10.0.1.2 - - [06/Feb/2009:12:14:14 +0100] "GET /index.html HTTP/1.1" 200 2750 "" "" 33317 655 "http-in" "static" "srv1" 10 0 30 69 109 "----" "" "" 1 1 1 1 0 0 0 "" ""
+	haproxyFormats = []haproxyFormat{
+		{
+			regex: regexp.MustCompile(`^(\S+):(\d+) \[([^\]]+)\] (\S+) ([^/\s]+)/(\S+) ` +
+				`(-?\d+)/(-?\d+)/(-?\d+)/(-?\d+)/(\+?-?\d+) (-?\d+) (\+?\d+) (\S+) (\S+) (\S{4}) ` +
+				`(\d+)/(\d+)/(\d+)/(\d+)/(\+?\d+) (\d+)/(\d+)(?: \{([^}]*)\})?(?: \{([^}]*)\})? "(.*?)"?$`),
+			fields: []string{
+				"clientIp", "clientPort", "acceptDate", "frontend", "backend", "server",
+				"tq", "tw", "tc", "tr", "tt", "status", "bytesRead", "requestCookie", "responseCookie", "terminationState",
+				"actconn", "feconn", "beconn", "srvConn", "retries", "srvQueue", "backendQueue",
+				"requestHeaders", "responseHeaders", "request",
+			},
+		},
+		{
+			regex: regexp.MustCompile(`^(\S+):(\d+) \[([^\]]+)\] (\S+) ([^/\s]+)/(\S+) ` +
+				`(-?\d+)/(-?\d+)/(\+?-?\d+) (\+?\d+) (\S{2}) (\d+)/(\d+)/(\d+)/(\d+)/(\+?\d+) (\d+)/(\d+)$`),
+			fields: []string{
+				"clientIp", "clientPort", "acceptDate", "frontend", "backend", "server",
+				"tw", "tc", "tt", "bytesRead", "terminationState",
+				"actconn", "feconn", "beconn", "srvConn", "retries", "srvQueue", "backendQueue",
+			},
+		},
+		{
+			regex: regexp.MustCompile(`^(\S+) - - \[([^\]]+)\] "(.*?)" (-?\d+) (\+?\d+) "" "" (\d+) (\d+) ` +
+				`"([^"]*)" "([^"]*)" "([^"]*)" (-?\d+) (-?\d+) (-?\d+) (-?\d+) (\+?-?\d+) "([^"]*)" "([^"]*)" "([^"]*)" ` +
+				`(\d+) (\d+) (\d+) (\d+) (\+?\d+) (\d+) (\d+) "([^"]*)" "([^"]*)"$`),
+			fields: []string{
+				"clientIp", "acceptDate", "request", "status", "bytesRead", "clientPort", "acceptDateMs",
+				"frontend", "backend", "server", "tq", "tw", "tc", "tr", "tt", "terminationState",
+				"requestCookie", "responseCookie", "actconn", "feconn", "beconn", "srvConn", "retries",
+				"srvQueue", "backendQueue", "requestHeaders", "responseHeaders",
+			},
+		},
+	}
+
+	// haproxyStringFields are kept as strings, all others are numbers.
+	haproxyStringFields = map[string]bool{
+		"clientIp": true, "acceptDate": true, "frontend": true, "backend": true, "server": true,
+		"requestCookie": true, "responseCookie": true, "terminationState": true,
+		"requestHeaders": true, "responseHeaders": true, "request": true,
+	}
+)
+
+// parseHAProxy parses the HTTP, TCP and CLF logs of HAProxy. The timers and
+// the connection and queue counters are split into fields of their own.
+// Numbers HAProxy prefixes with `+`, like the retries of redispatched
+// connections, stay strings.
+func parseHAProxy(msg *Log) bool {
+	text := strings.TrimSpace(msg.Text)
+	for _, format := range haproxyFormats {
+		values := format.regex.FindStringSubmatch(text)
+		if values == nil {
+			continue
+		}
+
+		for i, field := range format.fields {
+			value := values[i+1]
+			if value == "" || value == "-" {
+				continue
+			}
+
+			n, err := strconv.ParseInt(value, 10, 64)
+			if haproxyStringFields[field] || err != nil || value[0] == '+' {
+				msg.Metadata[haproxyFieldPrefix+field] = value
+			} else {
+				msg.Metadata[haproxyFieldPrefix+field] = n
+			}
+		}
+
+		// `GET /index.html HTTP/1.1`, anything else is kept as is
+		if request, ok := msg.Metadata[haproxyFieldPrefix+"request"].(string); ok {
+			if parts := strings.Fields(request); len(parts) == 3 {
+				msg.Metadata[haproxyFieldPrefix+"method"] = parts[0]
+				msg.Metadata[haproxyFieldPrefix+"path"] = parts[1]
+				msg.Metadata[haproxyFieldPrefix+"protocol"] = parts[2]
+			}
+		}
+		return true
+	}
+	return false
+}
@@ -0,0 +1,140 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseHAProxy(t *testing.T) {
+	cases := []struct {
+		raw      string
+		metadata map[string]any
+	}{
+		{
+			raw: `<150>Jan 18 11:07:53 lb haproxy[42]: 10.0.1.2:33317 [06/Feb/2009:12:14:14.655] http-in static/srv1 10/0/30/69/109 200 2750 - - ---- 1/1/1/1/0 0/0 {1wt.eu} {} "GET /index.html HTTP/1.1"`,
+			metadata: map[string]any{
+				"haproxy.clientIp":         "10.0.1.2",
+				"haproxy.clientPort":       int64(33317),
+				"haproxy.acceptDate":       "06/Feb/2009:12:14:14.655",
+				"haproxy.frontend":         "http-in",
+				"haproxy.backend":          "static",
+				"haproxy.server":           "srv1",
+				"haproxy.tq":               int64(10),
+				"haproxy.tw":               int64(0),
+				"haproxy.tc":               int64(30),
+				"haproxy.tr":               int64(69),
+				"haproxy.tt":               int64(109),
+				"haproxy.status":           int64(200),
+				"haproxy.bytesRead":        int64(2750),
+				"haproxy.terminationState": "----",
+				"haproxy.actconn":          int64(1),
+				"haproxy.feconn":           int64(1),
+				"haproxy.beconn":           int64(1),
+				"haproxy.srvConn":          int64(1),
+				"haproxy.retries":          int64(0),
+				"haproxy.srvQueue":         int64(0),
+				"haproxy.backendQueue":     int64(0),
+				"haproxy.requestHeaders":   "1wt.eu",
+				"haproxy.request":          "GET /index.html HTTP/1.1",
+				"haproxy.method":           "GET",
+				"haproxy.path":             "/index.html",
+				"haproxy.protocol":         "HTTP/1.1",
+			},
+		},
+		{
+			// without captures, aborted before a server was chosen
+			raw: `<150>Jan 18 11:07:53 lb haproxy[42]: [2001:db8::1]:33317 [06/Feb/2009:12:14:14.655] http-in~ http-in/<NOSRV> -1/-1/-1/-1/+3 408 +212 - - cR-- 2/2/0/0/+1 0/0 "<BADREQ>"`,
+			metadata: map[string]any{
+				"haproxy.clientIp":         "[2001:db8::1]",
+				"haproxy.clientPort":       int64(33317),
+				"haproxy.acceptDate":       "06/Feb/2009:12:14:14.655",
+				"haproxy.frontend":         "http-in~",
+				"haproxy.backend":          "http-in",
+				"haproxy.server":           "<NOSRV>",
+				"haproxy.tq":               int64(-1),
+				"haproxy.tw":               int64(-1),
+				"haproxy.tc":               int64(-1),
+				"haproxy.tr":               int64(-1),
+				"haproxy.tt":               "+3",
+				"haproxy.status":           int64(408),
+				"haproxy.bytesRead":        "+212",
+				"haproxy.terminationState": "cR--",
+				"haproxy.actconn":          int64(2),
+				"haproxy.feconn":           int64(2),
+				"haproxy.beconn":           int64(0),
+				"haproxy.srvConn":          int64(0),
+				"haproxy.retries":          "+1",
+				"haproxy.srvQueue":         int64(0),
+				"haproxy.backendQueue":     int64(0),
+				"haproxy.request":          "<BADREQ>",
+			},
+		},
+		{
+			raw: `<150>Jan 18 11:07:53 lb haproxy[42]: 10.0.1.2:33313 [06/Feb/2009:12:12:51.443] fnt bck/srv1 0/0/5007 212 -- 0/0/0/0/3 0/0`,
+			metadata: map[string]any{
+				"haproxy.clientIp":         "10.0.1.2",
+				"haproxy.clientPort":       int64(33313),
+				"haproxy.acceptDate":       "06/Feb/2009:12:12:51.443",
+				"haproxy.frontend":         "fnt",
+				"haproxy.backend":          "bck",
+				"haproxy.server":           "srv1",
+				"haproxy.tw":               int64(0),
+				"haproxy.tc":               int64(0),
+				"haproxy.tt":               int64(5007),
+				"haproxy.bytesRead":        int64(212),
+				"haproxy.terminationState": "--",
+				"haproxy.actconn":          int64(0),
+				"haproxy.feconn":           int64(0),
+				"haproxy.beconn":           int64(0),
+				"haproxy.srvConn":          int64(0),
+				"haproxy.retries":          int64(3),
+				"haproxy.srvQueue":         int64(0),
+				"haproxy.backendQueue":     int64(0),
+			},
+		},
+		{
+			raw: `<150>Jan 18 11:07:53 lb haproxy[42]: 10.0.1.2 - - [06/Feb/2009:12:14:14 +0100] "GET /index.html HTTP/1.1" 200 2750 "" "" 33317 014 "http-in" "static" "srv1" 10 0 30 69 109 "----" "" "" 1 1 1 1 0 0 0 "" ""`,
+			metadata: map[string]any{
+				"haproxy.clientIp":         "10.0.1.2",
+				"haproxy.acceptDate":       "06/Feb/2009:12:14:14 +0100",
+				"haproxy.request":          "GET /index.html HTTP/1.1",
+				"haproxy.method":           "GET",
+				"haproxy.path":             "/index.html",
+				"haproxy.protocol":         "HTTP/1.1",
+				"haproxy.status":           int64(200),
+				"haproxy.bytesRead":        int64(2750),
+				"haproxy.clientPort":       int64(33317),
+				"haproxy.acceptDateMs":     int64(14),
+				"haproxy.frontend":         "http-in",
+				"haproxy.backend":          "static",
+				"haproxy.server":           "srv1",
+				"haproxy.tq":               int64(10),
+				"haproxy.tw":               int64(0),
+				"haproxy.tc":               int64(30),
+				"haproxy.tr":               int64(69),
+				"haproxy.tt":               int64(109),
+				"haproxy.terminationState": "----",
+				"haproxy.actconn":          int64(1),
+				"haproxy.feconn":           int64(1),
+				"haproxy.beconn":           int64(1),
+				"haproxy.srvConn":          int64(1),
+				"haproxy.retries":          int64(0),
+				"haproxy.srvQueue":         int64(0),
+				"haproxy.backendQueue":     int64(0),
+			},
+		},
+		{
+			raw:      `<150>Jan 18 11:07:53 lb haproxy[42]: Proxy http-in started.`,
+			metadata: map[string]any{},
+		},
+	}
+
+	for _, c := range cases {
+		msg := ParseLineWithFallback([]byte(c.raw), "10.1.1.1")
+		require.NotNil(t, msg, c.raw)
+		assert.Equal(t, "haproxy", msg.Application, c.raw)
+		assert.Equal(t, c.metadata, msg.Metadata, c.raw)
+	}
+}
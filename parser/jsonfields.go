@@ -0,0 +1,233 @@
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/buger/jsonparser"
+)
+
+// JSONFields lists the fields of JSON messages that hold the timestamp,
+// hostname, application, text and severity of a message. Fields are matched
+// case-insensitively and may be paths into nested objects, like `log.level`,
+// which also match a top-level key of that name. When a message has several
+// of the fields, the one listed first wins.
+//
+// A nil list keeps the default fields, an empty one maps none.
+type JSONFields struct {
+	// Timestamp are the fields holding the timestamp, as a string or a
+	// number. Defaults to syslog.timestamp, timestamp, eventtime,
+	// @timestamp, _timestamp, ts, time and published_date.
+	Timestamp []string `json:"timestamp,omitempty"`
+	// Hostname are the fields holding the hostname. Defaults to
+	// syslog.hostname, hostname and host.
+	Hostname []string `json:"hostname,omitempty"`
+	// Application are the fields holding the application. Defaults to
+	// syslog.appname, app, application and service.name.
+	Application []string `json:"application,omitempty"`
+	// Message are the fields holding the message text. Defaults to
+	// message, msg and @message.
+	Message []string `json:"message,omitempty"`
+	// Severity are the fields holding the severity, as a name or a number:
+	// 0-7 are syslog severities, 10-60 the levels of pino and bunyan.
+	// Defaults to syslog.severity, severity, level and log.level.
+	Severity []string `json:"severity,omitempty"`
+}
+
+var defaultJSONFields = JSONFields{
+	Timestamp:   []string{"syslog.timestamp", "timestamp", "eventtime", "@timestamp", "_timestamp", "ts", "time", "published_date"},
+	Hostname:    []string{"syslog.hostname", "hostname", "host"},
+	Application: []string{"syslog.appname", "app", "application", "service.name"},
+	Message:     []string{"message", "msg", "@message"},
+	Severity:    []string{"syslog.severity", "severity", "level", "log.level"},
+}
+
+// defaultJSONFieldMap is used for applications without JSON fields of their
+// own.
+var defaultJSONFieldMap = compileJSONFields(JSONFields{})
+
+type jsonField int
+
+const (
+	jsonTimestamp jsonField = iota
+	jsonHostname
+	jsonApplication
+	jsonMessage
+	jsonSeverity
+	numJSONFields
+)
+
+type jsonPath struct {
+	field jsonField
+	rank  int
+}
+
+// jsonFieldMap is the compiled form of JSONFields.
+type jsonFieldMap struct {
+	// paths maps the lowercased paths onto the field they hold.
+	paths map[string]jsonPath
+	// parents holds the lowercased paths of the objects paths lead through.
+	parents map[string]bool
+}
+
+func compileJSONFields(fields JSONFields) *jsonFieldMap {
+	m := &jsonFieldMap{
+		paths:   map[string]jsonPath{},
+		parents: map[string]bool{},
+	}
+	lists := [numJSONFields][]string{fields.Timestamp, fields.Hostname, fields.Application, fields.Message, fields.Severity}
+	defaults := [numJSONFields][]string{defaultJSONFields.Timestamp, defaultJSONFields.Hostname, defaultJSONFields.Application, defaultJSONFields.Message, defaultJSONFields.Severity}
+	for field, paths := range lists {
+		if paths == nil {
+			paths = defaults[field]
+		}
+		for rank, path := range paths {
+			path = strings.ToLower(path)
+			if _, ok := m.paths[path]; ok {
+				continue
+			}
+			m.paths[path] = jsonPath{field: jsonField(field), rank: rank}
+			for i := range len(path) {
+				if path[i] == '.' {
+					m.parents[path[:i]] = true
+				}
+			}
+		}
+	}
+	return m
+}
+
+func compileJSONFieldMaps(fields map[string]JSONFields) (map[string]*jsonFieldMap, error) {
+	compiled := make(map[string]*jsonFieldMap, len(fields))
+	for app, f := range fields {
+		for _, path := range [][]string{f.Timestamp, f.Hostname, f.Application, f.Message, f.Severity} {
+			for _, p := range path {
+				if p == "" || strings.HasPrefix(p, ".") || strings.HasSuffix(p, ".") || strings.Contains(p, "..") {
+					return nil, fmt.Errorf("JSON fields of %q: invalid path %q", app, p)
+				}
+			}
+		}
+		compiled[app] = compileJSONFields(f)
+	}
+	return compiled, nil
+}
+
+// jsonFieldsFor returns the JSON fields configured for app.
+func (c *Config) jsonFieldsFor(app string) *jsonFieldMap {
+	if m, ok := c.jsonFields[app]; ok {
+		return m
+	}
+	if m, ok := c.jsonFields[anyApplication]; ok {
+		return m
+	}
+	return defaultJSONFieldMap
+}
+
+// jsonValue is a value found for one of the fields.
+type jsonValue struct {
+	value    []byte
+	dataType jsonparser.ValueType
+	rank     int
+	ok       bool
+	// key is the top-level key the value is found under, and path the keys
+	// below it, as they appear in the message.
+	key  []byte
+	path []string
+}
+
+// find returns the values of the fields in the JSON object data.
+func (m *jsonFieldMap) find(data []byte) (found [numJSONFields]jsonValue, err error) {
+	err = m.findIn(data, "", nil, nil, &found)
+	return found, err
+}
+
+func (m *jsonFieldMap) findIn(data []byte, parent string, top []byte, keys []string, found *[numJSONFields]jsonValue) error {
+	return jsonparser.ObjectEach(data, func(key []byte, value []byte, dataType jsonparser.ValueType, _ int) error {
+		// most keys are neither fields nor lead to one, so top-level ones are
+		// looked up without building their path where possible
+		var path string
+		if parent != "" || bytes.ContainsFunc(key, unicode.IsUpper) {
+			path = strings.ToLower(string(key))
+			if parent != "" {
+				path = parent + "." + path
+			}
+		}
+
+		var p jsonPath
+		var ok bool
+		if path == "" {
+			p, ok = m.paths[string(key)]
+		} else {
+			p, ok = m.paths[path]
+		}
+		if ok && validJSONField(p.field, value, dataType) {
+			if v := &found[p.field]; !v.ok || p.rank < v.rank {
+				*v = jsonValue{value: value, dataType: dataType, rank: p.rank, ok: true, key: key}
+				if top != nil {
+					v.key = top
+					v.path = append(keys[:len(keys):len(keys)], string(key))
+				}
+			}
+			return nil
+		}
+
+		if dataType != jsonparser.Object {
+			return nil
+		}
+		if path == "" {
+			if !m.parents[string(key)] {
+				return nil
+			}
+			path = string(key)
+		} else if !m.parents[path] {
+			return nil
+		}
+		if top == nil {
+			return m.findIn(value, path, key, nil, found)
+		}
+		return m.findIn(value, path, top, append(keys[:len(keys):len(keys)], string(key)), found)
+	})
+}
+
+// validJSONField reports whether value can be used as field. Values that
+// can't stay in the metadata.
+func validJSONField(field jsonField, value []byte, dataType jsonparser.ValueType) bool {
+	switch field {
+	case jsonTimestamp:
+		return dataType == jsonparser.String || dataType == jsonparser.Number
+	case jsonSeverity:
+		if dataType == jsonparser.Number {
+			n, err := ParseInt(value)
+			_, ok := severityFromLevel(n)
+			return err == nil && ok
+		}
+		return dataType == jsonparser.String
+	default:
+		return dataType == jsonparser.String
+	}
+}
+
+// severityFromLevel maps a numeric level onto a severity: 0-7 are syslog
+// severities, 10-60 the levels of pino and bunyan.
+func severityFromLevel(level int64) (int64, bool) {
+	switch level {
+	case 0, 1, 2, 3, 4, 5, 6, 7:
+		return level, true
+	case 10:
+		return Trace, true
+	case 20:
+		return Debug, true
+	case 30:
+		return Info, true
+	case 40:
+		return Warning, true
+	case 50:
+		return Error, true
+	case 60:
+		return Critical, true
+	default:
+		return 0, false
+	}
+}
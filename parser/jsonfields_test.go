@@ -0,0 +1,142 @@
+package parser
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONFields(t *testing.T) {
+	config := mustCompile(&Config{
+		JSONFields: map[string]JSONFields{
+			"*": {
+				Timestamp:   []string{"ts"},
+				Application: []string{"service.name"},
+				Message:     []string{"@message", "msg"},
+				Severity:    []string{"log.level"},
+			},
+			"billing": {
+				Message:  []string{"text"},
+				Severity: []string{},
+			},
+		},
+	})
+
+	msg := parseLineWithFallback([]byte(`{"ts": 1705576073, "log": {"level": 50, "logger": "api"}, "service": {"name": "web"}, "@message": "boom", "msg": "other", "status": 200, "host": "web-1"}`), "10.1.1.1", config)
+	require.NotNil(t, msg)
+	assert.Equal(t, time.Unix(1705576073, 0).UnixNano(), msg.Timestamp)
+	assert.Equal(t, "web-1", msg.Hostname)
+	assert.Equal(t, "web", msg.Application)
+	assert.Equal(t, "boom", msg.Text)
+	assert.EqualValues(t, Error, msg.Severity)
+	assert.Equal(t, map[string]any{"log.logger": "api", "msg": "other", "status": int64(200)}, msg.Metadata)
+
+	// by the application in the message
+	msg = parseLineWithFallback([]byte(`{"service": {"name": "billing"}, "text": "charged", "msg": "other", "level": "debug"}`), "10.1.1.1", config)
+	require.NotNil(t, msg)
+	assert.Equal(t, "billing", msg.Application)
+	assert.Equal(t, "charged", msg.Text)
+	assert.Equal(t, map[string]any{"msg": "other", "level": "debug"}, msg.Metadata)
+
+	// or in the syslog header
+	msg = parseLineWithFallback([]byte(`<14>Jan 18 11:07:53 host billing: {"text": "refunded"}`), "10.1.1.1", config)
+	require.NotNil(t, msg)
+	assert.Equal(t, "refunded", msg.Text)
+
+	_, err := NewWithConfig(func(*Log) {}, &Config{JSONFields: map[string]JSONFields{"*": {Severity: []string{"log..level"}}}})
+	assert.Error(t, err)
+}
+
+func TestJSONDefaultFields(t *testing.T) {
+	cases := []struct {
+		raw         string
+		timestamp   time.Time
+		application string
+		text        string
+		severity    int64
+		metadata    map[string]any
+	}{
+		{
+			raw:      `{"status": "ok", "level": "warn"}`,
+			severity: Warning,
+			metadata: map[string]any{"status": "ok"},
+		},
+		{
+			raw:      `{"log": {"level": "error"}, "level": "info"}`,
+			severity: Info,
+			metadata: map[string]any{"log.level": "error"},
+		},
+		{
+			raw:      `{"log": {"level": "warn"}}`,
+			severity: Warning,
+			metadata: map[string]any{},
+		},
+		{
+			raw:       `{"date": "2024-01-01", "ts": 1705576073}`,
+			timestamp: time.Unix(1705576073, 0),
+			metadata:  map[string]any{"date": "2024-01-01"},
+		},
+		{
+			raw:       `{"time": "2024-01-18T11:07:53Z", "@timestamp": "2024-01-18T12:00:00Z"}`,
+			timestamp: time.Date(2024, 1, 18, 12, 0, 0, 0, time.UTC),
+			metadata:  map[string]any{"time": "2024-01-18T11:07:53Z"},
+		},
+		{
+			raw:      `{"@message": "first", "msg": "second"}`,
+			text:     "second",
+			metadata: map[string]any{"@message": "first"},
+		},
+		{
+			raw:      `{"@message": "only"}`,
+			text:     "only",
+			metadata: map[string]any{},
+		},
+		{
+			raw:         `{"service": {"name": "web"}, "app": "api"}`,
+			application: "api",
+			metadata:    map[string]any{"service.name": "web"},
+		},
+		{
+			raw:         `{"service": {"name": "web", "version": "1.2"}}`,
+			application: "web",
+			metadata:    map[string]any{"service.version": "1.2"},
+		},
+	}
+
+	for _, c := range cases {
+		msg, err := parseJSON([]byte(c.raw), defaultConfig, "")
+		require.NoError(t, err, c.raw)
+		if !c.timestamp.IsZero() {
+			assert.Equal(t, c.timestamp.UnixNano(), msg.Timestamp, c.raw)
+		}
+		assert.Equal(t, c.application, msg.Application, c.raw)
+		assert.Equal(t, c.text, msg.Text, c.raw)
+		assert.Equal(t, c.severity, msg.Severity, c.raw)
+		assert.Equal(t, c.metadata, msg.Metadata, c.raw)
+	}
+}
+
+func TestJSONSeverityLevels(t *testing.T) {
+	levels := map[string]int64{
+		`{"level": 10}`:               Trace,
+		`{"level": 30}`:               Info,
+		`{"level": 40}`:               Warning,
+		`{"level": 60}`:               Error,
+		`{"severity": 4}`:             Warning,
+		`{"level": "warn"}`:           Warning,
+		`{"syslog": {"severity": 7}}`: Debug,
+	}
+	for raw, severity := range levels {
+		msg := ParseLineWithFallback([]byte(raw), "10.1.1.1")
+		require.NotNil(t, msg, raw)
+		assert.Equal(t, severity, msg.Severity, raw)
+		assert.Empty(t, msg.Metadata, raw)
+	}
+
+	// other numbers aren't levels
+	msg := ParseLineWithFallback([]byte(`{"status": 200}`), "10.1.1.1")
+	require.NotNil(t, msg)
+	assert.Equal(t, map[string]any{"status": int64(200)}, msg.Metadata)
+}
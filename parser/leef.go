@@ -0,0 +1,206 @@
+package parser
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	leefPrefix = "LEEF:"
+	// leefFieldPrefix namespaces the LEEF fields in Log.Metadata.
+	leefFieldPrefix = "leef."
+)
+
+// leefHeaderKeys are the names of the header fields following the version.
+var leefHeaderKeys = []string{"vendor", "product", "productVersion", "eventId"}
+
+// leefTimeLayouts are tried for devTime when no devTimeFormat is given.
+var leefTimeLayouts = []string{
+	"Jan 02 2006 15:04:05.000 MST",
+	"Jan 02 2006 15:04:05 MST",
+	"Jan 02 2006 15:04:05.000",
+	"Jan 02 2006 15:04:05",
+}
+
+// parseLEEF parses an IBM Log Event Extended Format payload in msg.Text:
+//
+//	LEEF:1.0|Vendor|Product|Version|EventID|Attributes
+//	LEEF:2.0|Vendor|Product|Version|EventID|Delimiter|Attributes
+//
+// Attributes are `key=value` pairs separated by tabs, or by the delimiter of
+// LEEF 2.0, which is either a single character or its hex code like `x09`.
+func parseLEEF(msg *Log) bool {
+	text := strings.TrimSpace(msg.Text)
+	if !strings.HasPrefix(text, leefPrefix) {
+		return false
+	}
+
+	header, attributes, ok := splitCEFHeader(text[len(leefPrefix):], len(leefHeaderKeys)+1)
+	if !ok {
+		return false
+	}
+
+	version := header[0]
+	delimiter := "\t"
+	if strings.HasPrefix(version, "2") {
+		// the delimiter is optional, so only take the field if it is one
+		if field, rest, ok := strings.Cut(attributes, "|"); ok {
+			if d, ok := parseLEEFDelimiter(field); ok {
+				delimiter = d
+				attributes = rest
+			}
+		}
+	}
+
+	msg.Metadata[leefFieldPrefix+"version"] = version
+	for i, key := range leefHeaderKeys {
+		msg.Metadata[leefFieldPrefix+key] = header[i+1]
+	}
+
+	var devTime, devTimeFormat, message string
+	for pair := range strings.SplitSeq(attributes, delimiter) {
+		key, value, ok := strings.Cut(pair, "=")
+		if key = strings.TrimSpace(key); !ok || key == "" {
+			continue
+		}
+
+		switch key {
+		case "devTime":
+			devTime = value
+		case "devTimeFormat":
+			devTimeFormat = value
+		case "sev":
+			// LEEF uses the same scale as CEF, from 1 to 10
+			if severity, ok := cefSeverity(value); ok {
+				msg.Severity = severity
+			}
+		case "msg":
+			message = value
+		}
+
+		msg.Metadata[leefFieldPrefix+key] = intOrString(value)
+	}
+
+	if devTime != "" {
+		parseLEEFTime(msg, devTime, devTimeFormat)
+	}
+
+	if msg.Application == "" || msg.Application == syntheticApplication {
+		msg.Application = header[2]
+	}
+	if message != "" {
+		msg.Text = message
+	}
+
+	return true
+}
+
+// parseLEEFDelimiter parses the delimiter field of LEEF 2.0, which is either a
+// single character or its hex code prefixed by `x` or `0x`.
+func parseLEEFDelimiter(field string) (string, bool) {
+	if len(field) == 1 {
+		return field, true
+	}
+
+	hex, ok := strings.CutPrefix(strings.ToLower(field), "0x")
+	if !ok {
+		hex, ok = strings.CutPrefix(strings.ToLower(field), "x")
+	}
+	if !ok || hex == "" || len(hex) > 4 {
+		return "", false
+	}
+
+	code, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return "", false
+	}
+	return string(rune(code)), true
+}
+
+// parseLEEFTime sets the timestamp of msg from devTime, which is either epoch
+// milliseconds or formatted according to the Java SimpleDateFormat pattern
+// in devTimeFormat.
+func parseLEEFTime(msg *Log, devTime, devTimeFormat string) {
+	layouts := leefTimeLayouts
+	if devTimeFormat != "" {
+		layout, ok := javaTimeLayout(devTimeFormat)
+		if !ok {
+			return
+		}
+		layouts = []string{layout}
+	}
+
+	ts, zoneless, ok := parseTimestamp(devTime, layouts)
+	if !ok {
+		return
+	}
+
+	if zoneless {
+		msg.wallClock = ts
+		msg.hasWallClock = true
+		ts = fromWallClock(ts, time.Local, time.Now())
+	}
+	msg.Timestamp = ts.UnixNano()
+}
+
+// javaTimeLayouts maps the letters of Java SimpleDateFormat patterns, by the
+// number of repetitions, to Go time layout elements.
+var javaTimeLayouts = map[byte][]string{
+	'y': {"2006", "06", "2006", "2006"},
+	'M': {"1", "01", "Jan", "January"},
+	'd': {"2", "02"},
+	'H': {"15", "15"},
+	'h': {"3", "03"},
+	'm': {"4", "04"},
+	's': {"5", "05"},
+	'E': {"Mon", "Mon", "Mon", "Monday"},
+	'a': {"PM"},
+	'z': {"MST", "MST", "MST", "MST"},
+	'Z': {"-0700"},
+	'X': {"Z07", "Z0700", "Z07:00"},
+}
+
+// javaTimeLayout converts a Java SimpleDateFormat pattern, as used by
+// devTimeFormat, into a Go time layout. It reports false for patterns Go
+// can't parse: `S` counts milliseconds, not a fraction of the second, so it
+// is only supported as the three digits of `SSS`.
+func javaTimeLayout(pattern string) (string, bool) {
+	var b strings.Builder
+	for i := 0; i < len(pattern); {
+		c := pattern[i]
+
+		// quoted literal text, where '' is a single quote
+		if c == '\'' {
+			end := strings.IndexByte(pattern[i+1:], '\'')
+			if end < 0 {
+				b.WriteString(pattern[i+1:])
+				break
+			}
+			if end == 0 {
+				b.WriteByte('\'')
+			}
+			b.WriteString(pattern[i+1 : i+1+end])
+			i += end + 2
+			continue
+		}
+
+		n := 1
+		for i+n < len(pattern) && pattern[i+n] == c {
+			n++
+		}
+
+		if c == 'S' {
+			if n != 3 {
+				return "", false
+			}
+			b.WriteString("000")
+		} else if elements, ok := javaTimeLayouts[c]; ok {
+			b.WriteString(elements[min(n, len(elements))-1])
+		} else {
+			b.WriteString(pattern[i : i+n])
+		}
+		i += n
+	}
+	return b.String(), true
+}
@@ -0,0 +1,140 @@
+package parser
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLEEF(t *testing.T) {
+	cases := []struct {
+		raw         string
+		hostname    string
+		application string
+		text        string
+		severity    int64
+		time        time.Time
+		metadata    map[string]any
+	}{
+		{
+			raw:         "<13>Jan 18 11:07:53 host LEEF:1.0|Microsoft|MSExchange|4.0.0|7732|src=192.0.2.0\tdst=172.50.123.1\tsev=5\tcat=anomaly\tsrcPort=81\tdevTime=1705576073000\tmsg=Unusual logon",
+			hostname:    "host",
+			application: "MSExchange",
+			text:        "Unusual logon",
+			severity:    Warning,
+			time:        time.Date(2024, 1, 18, 11, 7, 53, 0, time.UTC),
+			metadata: map[string]any{
+				"leef.version":        "1.0",
+				"leef.vendor":         "Microsoft",
+				"leef.product":        "MSExchange",
+				"leef.productVersion": "4.0.0",
+				"leef.eventId":        "7732",
+				"leef.src":            "192.0.2.0",
+				"leef.dst":            "172.50.123.1",
+				"leef.sev":            int64(5),
+				"leef.cat":            "anomaly",
+				"leef.srcPort":        int64(81),
+				"leef.devTime":        int64(1705576073000),
+				"leef.msg":            "Unusual logon",
+			},
+		},
+		{
+			raw:         "<13>Jan 18 11:07:53 lancope: LEEF:2.0|Lancope|StealthWatch|1.0|41|^|src=10.0.1.8^dst=10.0.0.5^sev=9^devTime=Jan 18 2024 11:07:53.123 UTC^devTimeFormat=MMM dd yyyy HH:mm:ss.SSS z",
+			application: "lancope",
+			text:        "LEEF:2.0|Lancope|StealthWatch|1.0|41|^|src=10.0.1.8^dst=10.0.0.5^sev=9^devTime=Jan 18 2024 11:07:53.123 UTC^devTimeFormat=MMM dd yyyy HH:mm:ss.SSS z",
+			severity:    Error,
+			time:        time.Date(2024, 1, 18, 11, 7, 53, 123000000, time.UTC),
+			metadata: map[string]any{
+				"leef.version":        "2.0",
+				"leef.vendor":         "Lancope",
+				"leef.product":        "StealthWatch",
+				"leef.productVersion": "1.0",
+				"leef.eventId":        "41",
+				"leef.src":            "10.0.1.8",
+				"leef.dst":            "10.0.0.5",
+				"leef.sev":            int64(9),
+				"leef.devTime":        "Jan 18 2024 11:07:53.123 UTC",
+				"leef.devTimeFormat":  "MMM dd yyyy HH:mm:ss.SSS z",
+			},
+		},
+		{
+			raw:         "LEEF:2.0|Vendor|Product|2|login|x09|usrName=alice\tsev=2\tdevTime=2024-01-18T11:07:53\tdevTimeFormat=yyyy-MM-dd'T'HH:mm:ss",
+			application: "Product",
+			severity:    Info,
+			time:        time.Date(2024, 1, 18, 11, 7, 53, 0, time.Local),
+			metadata: map[string]any{
+				"leef.version":        "2.0",
+				"leef.vendor":         "Vendor",
+				"leef.product":        "Product",
+				"leef.productVersion": "2",
+				"leef.eventId":        "login",
+				"leef.usrName":        "alice",
+				"leef.sev":            int64(2),
+				"leef.devTime":        "2024-01-18T11:07:53",
+				"leef.devTimeFormat":  "yyyy-MM-dd'T'HH:mm:ss",
+			},
+		},
+		{
+			// LEEF 2.0 without a delimiter field falls back to tabs
+			raw:         "LEEF:2.0|Vendor|Product|2|login|usrName=bob\tsev=1",
+			application: "Product",
+			severity:    Info,
+			metadata: map[string]any{
+				"leef.version":        "2.0",
+				"leef.vendor":         "Vendor",
+				"leef.product":        "Product",
+				"leef.productVersion": "2",
+				"leef.eventId":        "login",
+				"leef.usrName":        "bob",
+				"leef.sev":            int64(1),
+			},
+		},
+	}
+
+	for _, c := range cases {
+		msg := ParseLineWithFallback([]byte(c.raw), "10.1.1.1")
+		require.NotNil(t, msg, c.raw)
+
+		if c.hostname != "" {
+			assert.Equal(t, c.hostname, msg.Hostname, c.raw)
+		}
+		if c.text != "" {
+			assert.Equal(t, c.text, msg.Text, c.raw)
+		}
+		if !c.time.IsZero() {
+			assert.Equal(t, c.time.UnixNano(), msg.Timestamp, c.raw)
+		}
+		assert.Equal(t, c.application, msg.Application, c.raw)
+		assert.Equal(t, c.severity, msg.Severity, c.raw)
+		assert.Equal(t, c.metadata, msg.Metadata, c.raw)
+	}
+}
+
+func TestJavaTimeLayout(t *testing.T) {
+	var testData = map[string]string{
+		"MMM dd yyyy HH:mm:ss.SSS z": "Jan 02 2006 15:04:05.000 MST",
+		"yyyy-MM-dd'T'HH:mm:ssXXX":   "2006-01-02T15:04:05Z07:00",
+		"EEE, d MMM yy hh:mm a Z":    "Mon, 2 Jan 06 03:04 PM -0700",
+		"dd.MM.yyyy 'at' HH''mm":     "02.01.2006 at 15'04",
+	}
+
+	for pattern, layout := range testData {
+		actual, ok := javaTimeLayout(pattern)
+		assert.True(t, ok, pattern)
+		assert.Equal(t, layout, actual, pattern)
+	}
+
+	layout, ok := javaTimeLayout("ss.SSS")
+	require.True(t, ok)
+	ts, err := time.Parse(layout, "05.123")
+	require.NoError(t, err)
+	assert.Equal(t, 123000000, ts.Nanosecond())
+
+	// S counts milliseconds, so other numbers of digits aren't fractions
+	for _, pattern := range []string{"ss.S", "ss.SS", "ss.SSSS", "ss.SSSSSS", "ss.SSSSSSSSS"} {
+		_, ok := javaTimeLayout(pattern)
+		assert.False(t, ok, pattern)
+	}
+}
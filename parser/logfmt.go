@@ -0,0 +1,159 @@
+package parser
+
+import (
+	"strconv"
+	"strings"
+)
+
+// logfmtPair is a single `key=value` pair of a logfmt line.
+type logfmtPair struct {
+	key    string
+	value  string
+	quoted bool
+}
+
+// scanLogfmt tokenizes a logfmt line, calling fn for every `key=value` pair.
+// Keys and values are either bare, running up to the next space, or double
+// quoted with backslash escapes. Bare values may contain `=` and an empty
+// value is valid. Keys without a value, the words of free text, are skipped.
+func scanLogfmt(data string, fn func(pair logfmtPair)) {
+	i := 0
+	n := len(data)
+
+	for i < n {
+		for i < n && (data[i] == ' ' || data[i] == '\t') {
+			i++
+		}
+		if i >= n {
+			return
+		}
+
+		var key string
+		start := i
+		if data[i] == '"' {
+			key, i = scanLogfmtQuoted(data, i)
+		} else {
+			for i < n && data[i] > ' ' && data[i] != '=' && data[i] != '"' {
+				i++
+			}
+			key = data[start:i]
+		}
+
+		if i >= n || data[i] != '=' {
+			if i == start {
+				// a control character, skip it
+				i++
+			}
+			continue
+		}
+		i++
+
+		pair := logfmtPair{key: key}
+		if i < n && data[i] == '"' {
+			pair.value, i = scanLogfmtQuoted(data, i)
+			pair.quoted = true
+		} else {
+			start = i
+			for i < n && data[i] != ' ' && data[i] != '\t' {
+				i++
+			}
+			pair.value = data[start:i]
+		}
+
+		fn(pair)
+	}
+}
+
+// scanLogfmtQuoted reads the double quoted string starting at data[i] and
+// returns it unescaped along with the index following it. An unterminated
+// string runs to the end of data.
+func scanLogfmtQuoted(data string, i int) (string, int) {
+	start := i
+	i++
+
+	escaped := false
+	for ; i < len(data); i++ {
+		switch {
+		case escaped:
+			escaped = false
+		case data[i] == '\\':
+			escaped = true
+		case data[i] == '"':
+			quoted := data[start : i+1]
+			if s, err := strconv.Unquote(quoted); err == nil {
+				return s, i + 1
+			}
+			return unescapeQuotes(quoted[1 : len(quoted)-1]), i + 1
+		}
+	}
+
+	return unescapeQuotes(data[start+1:]), len(data)
+}
+
+// unescapeQuotes drops the backslashes in front of quotes and backslashes,
+// for strings strconv.Unquote doesn't accept.
+func unescapeQuotes(s string) string {
+	if !strings.Contains(s, "\\") {
+		return s
+	}
+	return strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(s)
+}
+
+// parseLogfmt extracts the `key=value` pairs of data into the metadata of
+// msg. Bare values are typed if they are unambiguously integers, floats or
+// booleans, quoted values are always strings.
+func parseLogfmt(msg *Log, data []byte) {
+	scanLogfmt(string(data), func(pair logfmtPair) {
+		if !metadataKeyRegex.MatchString(pair.key) {
+			return
+		}
+
+		if pair.quoted {
+			msg.Metadata[pair.key] = pair.value
+		} else {
+			msg.Metadata[pair.key] = logfmtValue(pair.value)
+		}
+	})
+}
+
+// logfmtValue types a bare value conservatively: anything that might be an
+// identifier, a version or otherwise not meant as a number stays a string.
+func logfmtValue(value string) any {
+	switch value {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+
+	if !isDecimal(value) {
+		return value
+	}
+	if n, ok := numberValue([]byte(value)); ok {
+		return n
+	}
+	return value
+}
+
+// isDecimal reports whether s is a plain decimal number, with an optional
+// minus sign, fractional part and exponent, and without leading zeros.
+func isDecimal(s string) bool {
+	s = strings.TrimPrefix(s, "-")
+	mantissa, exponent, hasExp := strings.Cut(strings.ToLower(s), "e")
+	intPart, fracPart, hasFrac := strings.Cut(mantissa, ".")
+	if hasExp && exponent != "" && (exponent[0] == '+' || exponent[0] == '-') {
+		exponent = exponent[1:]
+	}
+
+	if intPart == "" || (len(intPart) > 1 && intPart[0] == '0') || (hasFrac && fracPart == "") || (hasExp && exponent == "") {
+		return false
+	}
+	for _, part := range []string{intPart, fracPart, exponent} {
+		for i := 0; i < len(part); i++ {
+			if part[i] < '0' || part[i] > '9' {
+				return false
+			}
+		}
+	}
+	return true
+}
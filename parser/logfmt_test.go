@@ -0,0 +1,55 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLogfmt(t *testing.T) {
+	var testData = map[string]map[string]any{
+		`level=info msg="starting server" port=8080 ratio=0.5 tls=true`: {
+			"level": "info", "msg": "starting server", "port": int64(8080), "ratio": 0.5, "tls": true,
+		},
+		`msg="say \"hello\"" path="C:\\temp"`: {
+			"msg": `say "hello"`, "path": `C:\temp`,
+		},
+		`empty= quoted="" next=1`: {
+			"empty": "", "quoted": "", "next": int64(1),
+		},
+		`bare key=value another`: {
+			"key": "value",
+		},
+		`query=a=b&c=d version=1.2.3 id=007 neg=-3 exp=1e5 small=-1.5E-3 bad=1e+-5 hex=0x1f`: {
+			"query": "a=b&c=d", "version": "1.2.3", "id": "007", "neg": int64(-3), "exp": float64(100000), "small": -0.0015, "bad": "1e+-5", "hex": "0x1f",
+		},
+		`"quoted key"=1 big=18446744073709551615 huge=99999999999999999999 flag="true"`: {
+			"quoted key": int64(1), "big": uint64(18446744073709551615), "huge": "99999999999999999999", "flag": "true",
+		},
+		`msg="unterminated value`: {
+			"msg": "unterminated value",
+		},
+		`= hello =world`: {},
+	}
+
+	for raw, expected := range testData {
+		msg := &Log{Metadata: map[string]any{}}
+		parseLogfmt(msg, []byte(raw))
+		assert.Equal(t, expected, msg.Metadata, raw)
+	}
+}
+
+func TestLegacyMetadata(t *testing.T) {
+	config := mustCompile(&Config{LegacyMetadata: true})
+
+	// booleans are typed on both paths
+	raw := []byte(`<14>Jan 18 11:07:53 host app: level=info tls=true cached=false`)
+	msg := parseLineWithFallback(raw, "10.1.1.1", config)
+	assert.Equal(t, true, msg.Metadata["tls"])
+	assert.Equal(t, false, msg.Metadata["cached"])
+	assert.Equal(t, "info", msg.Metadata["level"])
+
+	msg = ParseLineWithFallback(raw, "10.1.1.1")
+	assert.Equal(t, true, msg.Metadata["tls"])
+	assert.Equal(t, false, msg.Metadata["cached"])
+}
@@ -0,0 +1,284 @@
+package parser
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// mailFieldPrefix namespaces the mail fields in Log.Metadata.
+	mailFieldPrefix = "mail."
+
+	mailQueueID  = mailFieldPrefix + "queue_id"
+	mailTo       = mailFieldPrefix + "to"
+	mailStatus   = mailFieldPrefix + "status"
+	mailClientIP = mailFieldPrefix + "client_ip"
+	mailResponse = mailFieldPrefix + "response"
+	mailSummary  = mailFieldPrefix + "summary"
+
+	// postfixRemoved is the status of the line qmgr writes once a message
+	// has left the queue.
+	postfixRemoved = "removed"
+
+	postfixApplication    = "postfix"
+	dovecotApplication    = "dovecot"
+	defaultMailTimeout    = 10 * time.Minute
+	postfixNoQueue        = "NOQUEUE"
+	postfixQueueIDPattern = `[0-9A-F]{6,}|[0-9A-Za-z]{12,}|` + postfixNoQueue
+)
+
+var (
+	// postfixRegex matches the lines about a message, `<queue ID>: <details>`.
+	postfixRegex = regexp.MustCompile(`^(` + postfixQueueIDPattern + `): (.*)$`)
+	// mailPairRegex matches the `key=value` pairs of Postfix and Dovecot.
+	// Values are separated by commas or spaces, unless enclosed in angle
+	// brackets.
+	mailPairRegex = regexp.MustCompile(`([\w-]+)=(<[^>]*>|[^,\s]*)`)
+	// postfixResponseRegex matches the reply of the remote server following
+	// the status.
+	postfixResponseRegex = regexp.MustCompile(`status=\w+ \((.*)\)$`)
+	// postfixRejectRegex matches the client and reply of rejected messages.
+	postfixRejectRegex = regexp.MustCompile(`^(?:milter-)?reject: \S+ from (\S+?): (.*?);`)
+	// mailClientIPRegex matches the address in `name[address]`.
+	mailClientIPRegex = regexp.MustCompile(`\[([^\]]+)\]`)
+	// dovecotRegex matches `<service>: <event>: <details>` or
+	// `<service>(<user>)<pid><session>: <details>`.
+	dovecotRegex = regexp.MustCompile(`^([\w-]+)(?:\(([^)]*)\)\S*)?: (?:([A-Z][\w ]*?)(?: \(([^)]*)\))?: )?(.*)$`)
+
+	// postfixIntFields and postfixFloatFields are converted to numbers.
+	postfixIntFields   = map[string]bool{"size": true, "nrcpt": true}
+	postfixFloatFields = map[string]bool{"delay": true}
+
+	// dovecotFields renames the fields of Dovecot. Others keep their name.
+	dovecotFields = map[string]string{
+		"user": "user", "method": "method", "rip": "client_ip", "lip": "local_ip", "session": "session",
+		"msgid": "message_id",
+	}
+)
+
+// parsePostfix extracts the fields of the lines the Postfix daemons write
+// about a message, which are linked by its queue ID.
+func parsePostfix(msg *Log) bool {
+	m := postfixRegex.FindStringSubmatch(msg.Text)
+	if m == nil {
+		return false
+	}
+
+	queueID, details := m[1], m[2]
+	if queueID != postfixNoQueue {
+		msg.Metadata[mailQueueID] = queueID
+	}
+
+	for _, pair := range mailPairRegex.FindAllStringSubmatch(details, -1) {
+		key, value := pair[1], strings.Trim(pair[2], "<>")
+		// the metadata parser doesn't know about the commas
+		delete(msg.Metadata, key)
+
+		field := mailFieldPrefix + strings.ReplaceAll(key, "-", "_")
+		switch {
+		case postfixIntFields[key]:
+			msg.Metadata[field] = intOrString(value)
+		case postfixFloatFields[key]:
+			if f, err := strconv.ParseFloat(value, 64); err == nil {
+				msg.Metadata[field] = f
+			} else {
+				msg.Metadata[field] = value
+			}
+		default:
+			msg.Metadata[field] = value
+		}
+	}
+
+	if response := postfixResponseRegex.FindStringSubmatch(details); response != nil {
+		msg.Metadata[mailResponse] = response[1]
+	}
+	if reject := postfixRejectRegex.FindStringSubmatch(details); reject != nil {
+		msg.Metadata[mailStatus] = "reject"
+		msg.Metadata[mailFieldPrefix+"client"] = reject[1]
+		msg.Metadata[mailResponse] = reject[2]
+	}
+	if details == postfixRemoved {
+		msg.Metadata[mailStatus] = postfixRemoved
+	}
+	if client, ok := msg.Metadata[mailFieldPrefix+"client"].(string); ok {
+		if ip := mailClientIPRegex.FindStringSubmatch(client); ip != nil {
+			msg.Metadata[mailClientIP] = ip[1]
+		}
+	}
+
+	return true
+}
+
+// parseDovecot extracts the logins, logouts and deliveries of Dovecot.
+func parseDovecot(msg *Log) bool {
+	m := dovecotRegex.FindStringSubmatch(msg.Text)
+	if m == nil {
+		return false
+	}
+
+	service, user, event, reason, details := m[1], m[2], m[3], m[4], m[5]
+	var status string
+	switch {
+	case strings.Contains(reason, "auth failed"):
+		status = "auth_failed"
+	case event == "Login":
+		status = "login"
+	case strings.HasPrefix(details, "Logged out") || strings.HasPrefix(details, "Disconnected"):
+		status = "logout"
+	case strings.Contains(details, "saved mail to"):
+		status = "delivered"
+	default:
+		return false
+	}
+
+	msg.Metadata[mailFieldPrefix+"service"] = service
+	msg.Metadata[mailStatus] = status
+	if user != "" {
+		msg.Metadata[mailFieldPrefix+"user"] = user
+	}
+	for _, pair := range mailPairRegex.FindAllStringSubmatch(details, -1) {
+		key, value := pair[1], strings.Trim(pair[2], "<>")
+		if value == "" {
+			continue
+		}
+		name, ok := dovecotFields[key]
+		if !ok {
+			name = key
+		}
+		delete(msg.Metadata, key)
+		msg.Metadata[mailFieldPrefix+name] = value
+	}
+
+	return true
+}
+
+// isPostfix reports whether the application is a Postfix daemon, like
+// `postfix/smtpd` or `postfix-out/qmgr` of a secondary instance.
+func isPostfix(application string) bool {
+	prefix, _, ok := strings.Cut(application, "/")
+	return ok && strings.HasPrefix(prefix, postfixApplication)
+}
+
+// mailKey identifies a message in the queue of a mail server.
+type mailKey struct {
+	remoteAddr string
+	hostname   string
+	queueID    string
+}
+
+type mailMessage struct {
+	summary *Log
+	to      []string
+	updated time.Time
+}
+
+func (m *mailMessage) add(msg *Log) {
+	if m.summary == nil {
+		m.summary = &Log{
+			RemoteAddr:       msg.RemoteAddr,
+			ResolvedHostname: msg.ResolvedHostname,
+			Hostname:         msg.Hostname,
+			Application:      postfixApplication,
+			Severity:         Info,
+			Metadata:         map[string]any{},
+		}
+	}
+	m.summary.Timestamp = msg.Timestamp
+	if msg.Severity < m.summary.Severity {
+		m.summary.Severity = msg.Severity
+	}
+
+	for key, value := range msg.Metadata {
+		if !strings.HasPrefix(key, mailFieldPrefix) || key == mailStatus && value == postfixRemoved {
+			continue
+		}
+		if to, ok := value.(string); ok && key == mailTo {
+			m.to = append(m.to, to)
+			continue
+		}
+		m.summary.Metadata[key] = value
+	}
+}
+
+// complete returns the summary of the message.
+func (m *mailMessage) complete() *Log {
+	metadata := m.summary.Metadata
+	if len(m.to) > 0 {
+		metadata[mailTo] = strings.Join(m.to, ", ")
+	}
+	metadata[mailSummary] = true
+
+	var text strings.Builder
+	text.WriteString(metadata[mailQueueID].(string) + ":")
+	for _, key := range []string{"from", "to", "status"} {
+		if value, ok := metadata[mailFieldPrefix+key].(string); ok {
+			text.WriteString(" " + key + "=" + value)
+		}
+	}
+	m.summary.Text = text.String()
+	return m.summary
+}
+
+// mailBuffer correlates the lines of messages passing a Postfix queue and
+// summarizes each message once it was removed from the queue, wasn't heard of
+// within timeout or is the oldest of more than maxPending.
+type mailBuffer struct {
+	mu      sync.Mutex
+	pending *pendingMap[mailKey, *mailMessage]
+	timeout time.Duration
+}
+
+func newMailBuffer(timeout time.Duration, maxPending int) *mailBuffer {
+	return &mailBuffer{
+		pending: newPendingMap[mailKey, *mailMessage](maxPending),
+		timeout: timeout,
+	}
+}
+
+// add adds the details of msg to the message of its queue ID. It returns the
+// summaries of the messages that are complete.
+func (b *mailBuffer) add(msg *Log, now time.Time) []*Log {
+	queueID, ok := msg.Metadata[mailQueueID].(string)
+	if !ok || !isPostfix(msg.Application) {
+		return nil
+	}
+	key := mailKey{remoteAddr: msg.RemoteAddr, hostname: msg.Hostname, queueID: queueID}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var complete []*Log
+	m, ok := b.pending.get(key)
+	if !ok {
+		m = &mailMessage{}
+		if evicted, ok := b.pending.add(key, m); ok {
+			complete = append(complete, evicted.complete())
+		}
+	}
+	m.add(msg)
+	m.updated = now
+	if msg.Metadata[mailStatus] != postfixRemoved {
+		return complete
+	}
+
+	b.pending.delete(key)
+	return append(complete, m.complete())
+}
+
+// expire removes the messages that weren't heard of within the timeout, or
+// all of them, and returns their summaries.
+func (b *mailBuffer) expire(now time.Time, all bool) []*Log {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var expired []*Log
+	for _, m := range b.pending.removeIf(func(m *mailMessage) bool {
+		return all || now.Sub(m.updated) >= b.timeout
+	}) {
+		expired = append(expired, m.complete())
+	}
+	return expired
+}
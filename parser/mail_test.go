@@ -0,0 +1,167 @@
+package parser
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMail(t *testing.T) {
+	cases := []struct {
+		raw      string
+		metadata map[string]any
+	}{
+		{
+			raw: "<22>Jan 18 11:07:53 mx postfix/smtpd[41]: 4F1C22A0B1: client=unknown[10.0.0.1]",
+			metadata: map[string]any{
+				"mail.queue_id": "4F1C22A0B1", "mail.client": "unknown[10.0.0.1]", "mail.client_ip": "10.0.0.1",
+			},
+		},
+		{
+			raw: "<22>Jan 18 11:07:53 mx postfix/qmgr[42]: 4F1C22A0B1: from=<alice@example.com>, size=1234, nrcpt=1 (queue active)",
+			metadata: map[string]any{
+				"mail.queue_id": "4F1C22A0B1", "mail.from": "alice@example.com", "mail.size": int64(1234), "mail.nrcpt": int64(1),
+			},
+		},
+		{
+			raw: "<22>Jan 18 11:07:53 mx postfix/smtp[43]: 4F1C22A0B1: to=<bob@example.org>, relay=mx.example.org[198.51.100.7]:25, delay=0.52, delays=0.1/0/0.2/0.22, dsn=2.0.0, status=sent (250 2.0.0 OK)",
+			metadata: map[string]any{
+				"mail.queue_id": "4F1C22A0B1", "mail.to": "bob@example.org", "mail.relay": "mx.example.org[198.51.100.7]:25",
+				"mail.delay": 0.52, "mail.delays": "0.1/0/0.2/0.22", "mail.dsn": "2.0.0", "mail.status": "sent",
+				"mail.response": "250 2.0.0 OK",
+			},
+		},
+		{
+			raw: "<22>Jan 18 11:07:53 mx postfix-out/cleanup[44]: 4Q2XyZ5kBqz9sWQ: message-id=<abc@example.com>",
+			metadata: map[string]any{
+				"mail.queue_id": "4Q2XyZ5kBqz9sWQ", "mail.message_id": "abc@example.com",
+			},
+		},
+		{
+			raw: "<22>Jan 18 11:07:53 mx postfix/smtpd[41]: NOQUEUE: reject: RCPT from unknown[10.0.0.9]: 554 5.7.1 <bob@example.org>: Relay access denied; from=<spam@example.net> to=<bob@example.org> proto=ESMTP helo=<x>",
+			metadata: map[string]any{
+				"mail.status": "reject", "mail.client": "unknown[10.0.0.9]", "mail.client_ip": "10.0.0.9",
+				"mail.response": "554 5.7.1 <bob@example.org>: Relay access denied", "mail.from": "spam@example.net",
+				"mail.to": "bob@example.org", "mail.proto": "ESMTP", "mail.helo": "x",
+			},
+		},
+		{
+			raw: "<22>Jan 18 11:07:53 mx dovecot: imap-login: Login: user=<bob>, method=PLAIN, rip=10.0.0.1, lip=10.0.0.2, mpid=1234, TLS, session=<abc>",
+			metadata: map[string]any{
+				"mail.service": "imap-login", "mail.status": "login", "mail.user": "bob", "mail.method": "PLAIN",
+				"mail.client_ip": "10.0.0.1", "mail.local_ip": "10.0.0.2", "mail.mpid": "1234", "mail.session": "abc",
+			},
+		},
+		{
+			raw: "<22>Jan 18 11:07:53 mx dovecot: imap(bob)<1234><abc>: Logged out in=120 out=4567 deleted=0 expunged=0",
+			metadata: map[string]any{
+				"mail.service": "imap", "mail.status": "logout", "mail.user": "bob", "mail.in": "120", "mail.out": "4567",
+				"mail.deleted": "0", "mail.expunged": "0",
+			},
+		},
+		{
+			raw: "<22>Jan 18 11:07:53 mx dovecot: imap-login: Disconnected (auth failed, 1 attempts in 2 secs): user=<bob>, method=PLAIN, rip=10.0.0.1, lip=10.0.0.2, TLS, session=<abc>",
+			metadata: map[string]any{
+				"mail.service": "imap-login", "mail.status": "auth_failed", "mail.user": "bob", "mail.method": "PLAIN",
+				"mail.client_ip": "10.0.0.1", "mail.local_ip": "10.0.0.2", "mail.session": "abc",
+			},
+		},
+		{
+			raw: "<22>Jan 18 11:07:53 mx dovecot: lmtp(bob)<1234><abc>: msgid=<abc@example.com>: saved mail to INBOX",
+			metadata: map[string]any{
+				"mail.service": "lmtp", "mail.status": "delivered", "mail.user": "bob", "mail.message_id": "abc@example.com",
+			},
+		},
+	}
+
+	for _, c := range cases {
+		msg := ParseLineWithFallback([]byte(c.raw), "10.1.1.1")
+		require.NotNil(t, msg, c.raw)
+		assert.Equal(t, c.metadata, msg.Metadata, c.raw)
+	}
+}
+
+func TestCorrelateMail(t *testing.T) {
+	var logs []*Log
+	p, err := NewWithConfig(func(msg *Log) { logs = append(logs, msg) }, &Config{MailCorrelation: true})
+	require.NoError(t, err)
+
+	lines := []string{
+		"<22>Jan 18 11:07:50 mx postfix/smtpd[41]: 4F1C22A0B1: client=unknown[10.0.0.1]",
+		"<22>Jan 18 11:07:51 mx postfix/qmgr[42]: 4F1C22A0B1: from=<alice@example.com>, size=1234, nrcpt=2 (queue active)",
+		"<22>Jan 18 11:07:51 mx postfix/qmgr[42]: 5A0B33C1D2: from=<carol@example.com>, size=99, nrcpt=1 (queue active)",
+		"<22>Jan 18 11:07:52 mx postfix/smtp[43]: 4F1C22A0B1: to=<bob@example.org>, relay=mx.example.org[198.51.100.7]:25, delay=0.52, dsn=2.0.0, status=sent (250 OK)",
+		"<22>Jan 18 11:07:53 mx postfix/smtp[43]: 4F1C22A0B1: to=<dave@example.org>, relay=mx.example.org[198.51.100.7]:25, delay=1.5, dsn=2.0.0, status=sent (250 OK)",
+		"<22>Jan 18 11:07:53 mx postfix/qmgr[42]: 4F1C22A0B1: removed",
+	}
+	for _, line := range lines {
+		p.WriteLine([]byte(line), "10.1.1.1")
+	}
+
+	require.Len(t, logs, len(lines)+1)
+	summary := logs[len(logs)-1]
+	assert.Equal(t, "postfix", summary.Application)
+	assert.Equal(t, "mx", summary.Hostname)
+	assert.Equal(t, "4F1C22A0B1: from=alice@example.com to=bob@example.org, dave@example.org status=sent", summary.Text)
+	assert.Equal(t, bsdDate(1, 18, 11, 7, 53, 0, time.Local).UnixNano(), summary.Timestamp)
+	assert.Equal(t, map[string]any{
+		"mail.queue_id":  "4F1C22A0B1",
+		"mail.client":    "unknown[10.0.0.1]",
+		"mail.client_ip": "10.0.0.1",
+		"mail.from":      "alice@example.com",
+		"mail.size":      int64(1234),
+		"mail.nrcpt":     int64(2),
+		"mail.to":        "bob@example.org, dave@example.org",
+		"mail.relay":     "mx.example.org[198.51.100.7]:25",
+		"mail.delay":     1.5,
+		"mail.dsn":       "2.0.0",
+		"mail.status":    "sent",
+		"mail.response":  "250 OK",
+		"mail.summary":   true,
+	}, summary.Metadata)
+
+	// messages that are still queued are summarized on stop
+	logs = nil
+	require.NoError(t, p.Flush())
+	assert.Empty(t, logs)
+	require.NoError(t, p.Stop())
+	require.Len(t, logs, 1)
+	assert.Equal(t, "5A0B33C1D2: from=carol@example.com", logs[0].Text)
+}
+
+func TestCorrelateMailTimeout(t *testing.T) {
+	var logs []*Log
+	p, err := NewWithConfig(func(msg *Log) { logs = append(logs, msg) }, &Config{MailCorrelation: true, MailTimeout: Duration(time.Nanosecond)})
+	require.NoError(t, err)
+
+	p.WriteLine([]byte("<22>Jan 18 11:07:53 mx postfix/smtp[43]: 4F1C22A0B1: to=<bob@example.org>, relay=none, delay=300, status=deferred (connection timed out)"), "10.1.1.1")
+	require.Len(t, logs, 1)
+
+	time.Sleep(time.Millisecond)
+	require.NoError(t, p.Flush())
+	require.Len(t, logs, 2)
+	assert.Equal(t, "4F1C22A0B1: to=bob@example.org status=deferred", logs[1].Text)
+}
+
+func TestCorrelateMailMaxPending(t *testing.T) {
+	var summaries []*Log
+	p, err := NewWithConfig(func(msg *Log) {
+		if msg.Metadata[mailSummary] == true {
+			summaries = append(summaries, msg)
+		}
+	}, &Config{MailCorrelation: true, MaxPendingMails: 2})
+	require.NoError(t, err)
+
+	for _, queueID := range []string{"4F1C22A0B1", "4F1C22A0B2", "4F1C22A0B3"} {
+		p.WriteLine([]byte("<22>Jan 18 11:07:53 mx postfix/smtp[43]: "+queueID+": to=<bob@example.org>, relay=none, delay=300, status=deferred (connection timed out)"), "10.1.1.1")
+	}
+
+	// the message heard of the longest time ago makes room
+	require.Len(t, summaries, 1)
+	assert.Equal(t, "4F1C22A0B1: to=bob@example.org status=deferred", summaries[0].Text)
+
+	_, err = NewWithConfig(func(*Log) {}, &Config{MaxPendingMails: -1})
+	assert.Error(t, err)
+}
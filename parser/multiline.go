@@ -0,0 +1,263 @@
+package parser
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultMultilineContinuation matches the lines of Java stack traces
+	// that can't be messages of their own: indented frames, the frames left
+	// out and causes.
+	defaultMultilineContinuation = `^(?:\s+at |\s+\.\.\. \d+ |Caused by: )`
+	// exceptionMultilineContinuation also matches the lines naming an
+	// exception, which may as well start a message, and Python tracebacks:
+	// the traceback header, any indented line and the exception closing it.
+	exceptionMultilineContinuation = `^(?:\s|Caused by: |Traceback \(most recent call last\):|[\w.]+(?:Error|Exception)(?::|$))`
+
+	defaultMultilineMaxLines = 1000
+	defaultMultilineMaxBytes = 1 << 20
+	defaultMultilineTimeout  = 2 * time.Second
+)
+
+// MultilineRule joins the lines of the matching applications and hosts that
+// belong to one message, like the frames of a stack trace, into a single
+// message. Lines are grouped per sender, hostname, application and process.
+//
+// A line matching Start begins a new message, a line matching Continuation
+// is appended to the message before it. If only one of them is set, all
+// other lines do the opposite. If both are, lines matching neither are
+// messages of their own. Without either, Continuation defaults to a pattern
+// for the frames and causes of Java stack traces.
+type MultilineRule struct {
+	// Application matches the application of a message and may contain `*`
+	// wildcards.
+	Application string `json:"application,omitempty"`
+	// Hostname matches the hostname of a message and may contain `*`
+	// wildcards.
+	Hostname string `json:"hostname,omitempty"`
+	// Start is the regular expression matching the first line of a message.
+	Start string `json:"start,omitempty"`
+	// Continuation is the regular expression matching the lines that
+	// continue a message.
+	Continuation string `json:"continuation,omitempty"`
+	// Exceptions extends the default Continuation to the lines naming an
+	// exception, like `java.lang.IllegalStateException: boom`, and to
+	// Python tracebacks. As they are appended to whatever message came
+	// before them, it is only meant for applications that don't log such
+	// lines on their own.
+	Exceptions bool `json:"exceptions,omitempty"`
+	// MaxLines is the number of lines after which a message is emitted as
+	// far as it got. Defaults to 1000.
+	MaxLines int `json:"maxLines,omitempty"`
+	// MaxBytes is the size in bytes after which a message is emitted as far
+	// as it got. Defaults to 1 MiB.
+	MaxBytes int `json:"maxBytes,omitempty"`
+	// Timeout is how long a message waits for its next line. Defaults to 2s.
+	Timeout Duration `json:"timeout,omitempty"`
+}
+
+type multilineRule struct {
+	application  string
+	hostname     string
+	start        *regexp.Regexp
+	continuation *regexp.Regexp
+	maxLines     int
+	maxBytes     int
+	timeout      time.Duration
+}
+
+func (r *multilineRule) matches(msg *Log) bool {
+	if r.application != "" {
+		if ok, _ := path.Match(r.application, msg.Application); !ok {
+			return false
+		}
+	}
+	if r.hostname != "" {
+		if ok, _ := path.Match(r.hostname, strings.ToLower(msg.Hostname)); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// continues reports whether line continues the message before it, and
+// whether it may be continued itself.
+func (r *multilineRule) continues(line string) (continues, continuable bool) {
+	switch {
+	case r.start != nil && r.start.MatchString(line):
+		return false, true
+	case r.continuation != nil && r.continuation.MatchString(line):
+		return true, true
+	case r.start == nil:
+		return false, true
+	case r.continuation == nil:
+		return true, true
+	default:
+		return false, false
+	}
+}
+
+func compileMultilineRules(rules []MultilineRule) ([]multilineRule, error) {
+	compiled := make([]multilineRule, 0, len(rules))
+	for i, rule := range rules {
+		if rule.Application == "" && rule.Hostname == "" {
+			return nil, fmt.Errorf("multiline rule %d: rule must set application, hostname or both", i)
+		}
+		if rule.MaxLines < 0 || rule.MaxBytes < 0 {
+			return nil, fmt.Errorf("multiline rule %d: limits must not be negative", i)
+		}
+
+		r := multilineRule{
+			application: rule.Application,
+			hostname:    strings.ToLower(rule.Hostname),
+			maxLines:    orDefault(rule.MaxLines, defaultMultilineMaxLines),
+			maxBytes:    orDefault(rule.MaxBytes, defaultMultilineMaxBytes),
+			timeout:     rule.Timeout.orDefault(defaultMultilineTimeout),
+		}
+		for _, glob := range []string{r.application, r.hostname} {
+			if _, err := path.Match(glob, ""); err != nil {
+				return nil, fmt.Errorf("multiline rule %d: %q: %w", i, glob, err)
+			}
+		}
+
+		continuation := rule.Continuation
+		if rule.Start == "" && continuation == "" {
+			continuation = defaultMultilineContinuation
+			if rule.Exceptions {
+				continuation = exceptionMultilineContinuation
+			}
+		}
+		var err error
+		if rule.Start != "" {
+			if r.start, err = regexp.Compile(rule.Start); err != nil {
+				return nil, fmt.Errorf("multiline rule %d: start: %w", i, err)
+			}
+		}
+		if continuation != "" {
+			if r.continuation, err = regexp.Compile(continuation); err != nil {
+				return nil, fmt.Errorf("multiline rule %d: continuation: %w", i, err)
+			}
+		}
+
+		compiled = append(compiled, r)
+	}
+	return compiled, nil
+}
+
+// multilineKey identifies the stream the lines of a message come from.
+type multilineKey struct {
+	remoteAddr  string
+	hostname    string
+	application string
+	procID      string
+}
+
+type multilineLog struct {
+	msg     *Log
+	rule    *multilineRule
+	lines   []string
+	size    int
+	updated time.Time
+}
+
+// complete returns the joined message.
+func (m *multilineLog) complete() *Log {
+	m.msg.Text = strings.Join(m.lines, "\n")
+	return m.msg
+}
+
+// multilineBuffer joins the lines of messages according to the multiline
+// rules of a config. Once more than maxPending messages are waiting for their
+// next line, the one continued the longest time ago is given up on.
+type multilineBuffer struct {
+	mu         sync.Mutex
+	rules      []multilineRule
+	pending    *pendingMap[multilineKey, *multilineLog]
+	maxRawSize int
+}
+
+func newMultilineBuffer(rules []multilineRule, maxPending, maxRawSize int) *multilineBuffer {
+	return &multilineBuffer{
+		rules:      rules,
+		pending:    newPendingMap[multilineKey, *multilineLog](maxPending),
+		maxRawSize: maxRawSize,
+	}
+}
+
+// add adds the line in msg to the message it belongs to. It returns the
+// messages that are complete, in order.
+func (b *multilineBuffer) add(msg *Log, now time.Time) []*Log {
+	var rule *multilineRule
+	for i := range b.rules {
+		if b.rules[i].matches(msg) {
+			rule = &b.rules[i]
+			break
+		}
+	}
+	if rule == nil {
+		return []*Log{msg}
+	}
+
+	key := multilineKey{
+		remoteAddr:  msg.RemoteAddr,
+		hostname:    msg.Hostname,
+		application: msg.Application,
+		procID:      msg.ProcID,
+	}
+	continues, continuable := rule.continues(msg.Text)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var complete []*Log
+	m, ok := b.pending.get(key)
+	if ok && continues {
+		m.lines = append(m.lines, msg.Text)
+		m.msg.Raw = joinRaw(m.msg.Raw, msg.Raw, b.maxRawSize)
+		m.size += len(msg.Text) + 1
+		m.updated = now
+		if len(m.lines) < rule.maxLines && m.size < rule.maxBytes {
+			return nil
+		}
+		b.pending.delete(key)
+		return append(complete, m.complete())
+	}
+
+	if ok {
+		b.pending.delete(key)
+		complete = append(complete, m.complete())
+	}
+	if !continuable {
+		return append(complete, msg)
+	}
+	if evicted, ok := b.pending.add(key, &multilineLog{
+		msg:     msg,
+		rule:    rule,
+		lines:   []string{msg.Text},
+		size:    len(msg.Text),
+		updated: now,
+	}); ok {
+		complete = append(complete, evicted.complete())
+	}
+	return complete
+}
+
+// expire removes the messages that weren't continued within the timeout of
+// their rule, or all of them, and returns them as far as they got.
+func (b *multilineBuffer) expire(now time.Time, all bool) []*Log {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var expired []*Log
+	for _, m := range b.pending.removeIf(func(m *multilineLog) bool {
+		return all || now.Sub(m.updated) >= m.rule.timeout
+	}) {
+		expired = append(expired, m.complete())
+	}
+	return expired
+}
@@ -0,0 +1,192 @@
+package parser
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMultiline(t *testing.T) {
+	var logs []*Log
+	p, err := NewWithConfig(func(msg *Log) { logs = append(logs, msg) }, &Config{
+		Multiline: []MultilineRule{{Application: "java-*", Exceptions: true}, {Application: "worker", Start: `^\d{4}-\d{2}-\d{2} `}},
+	})
+	require.NoError(t, err)
+
+	for _, line := range []string{
+		"<11>Jan 18 11:07:53 host java-api[42]: ERROR request failed",
+		"<11>Jan 18 11:07:53 host java-api[42]: java.lang.IllegalStateException: boom",
+		"<11>Jan 18 11:07:53 host java-api[43]: another process",
+		"<11>Jan 18 11:07:53 host java-api[42]: \tat com.example.Api.handle(Api.java:42)",
+		"<11>Jan 18 11:07:53 host java-api[42]: Caused by: java.io.IOException: closed",
+		"<11>Jan 18 11:07:53 host java-api[42]: \t... 3 more",
+		"<11>Jan 18 11:07:53 host app[42]: not joined",
+		"<11>Jan 18 11:07:53 host java-api[42]: INFO next message",
+	} {
+		p.WriteLine([]byte(line), "10.1.1.1")
+	}
+
+	require.Len(t, logs, 2)
+	assert.Equal(t, "not joined", logs[0].Text)
+	assert.Equal(t, "ERROR request failed\n"+
+		"java.lang.IllegalStateException: boom\n"+
+		"\tat com.example.Api.handle(Api.java:42)\n"+
+		"Caused by: java.io.IOException: closed\n"+
+		"\t... 3 more", logs[1].Text)
+	assert.Equal(t, int64(42), logs[1].PID)
+
+	// the pending messages are emitted on stop
+	logs = nil
+	require.NoError(t, p.Flush())
+	assert.Empty(t, logs)
+	require.NoError(t, p.Stop())
+	require.Len(t, logs, 2)
+	texts := []string{logs[0].Text, logs[1].Text}
+	assert.ElementsMatch(t, []string{"another process", "INFO next message"}, texts)
+
+	// start patterns
+	logs = nil
+	for _, line := range []string{
+		"<11>Jan 18 11:07:53 host worker: 2024-01-18 11:07:53 Traceback (most recent call last):",
+		`<11>Jan 18 11:07:53 host worker:   File "job.py", line 3, in run`,
+		"<11>Jan 18 11:07:53 host worker: ValueError: boom",
+		"<11>Jan 18 11:07:53 host worker: 2024-01-18 11:07:54 done",
+	} {
+		p.WriteLine([]byte(line), "10.1.1.1")
+	}
+	require.Len(t, logs, 1)
+	assert.Equal(t, "2024-01-18 11:07:53 Traceback (most recent call last):\n  File \"job.py\", line 3, in run\nValueError: boom", logs[0].Text)
+}
+
+func TestMultilineDefault(t *testing.T) {
+	var logs []*Log
+	p, err := NewWithConfig(func(msg *Log) { logs = append(logs, msg) }, &Config{
+		Multiline: []MultilineRule{{Application: "api"}},
+	})
+	require.NoError(t, err)
+
+	for _, line := range []string{
+		"<11>Jan 18 11:07:53 host api[42]: request failed",
+		"<11>Jan 18 11:07:53 host api[42]: Error: connection refused",
+		"<11>Jan 18 11:07:53 host api[42]: java.lang.IllegalStateException: boom",
+		"<11>Jan 18 11:07:53 host api[42]: \tat com.example.Api.handle(Api.java:42)",
+		"<11>Jan 18 11:07:53 host api[42]: Caused by: java.io.IOException: closed",
+		"<11>Jan 18 11:07:53 host api[42]: \t... 3 more",
+	} {
+		p.WriteLine([]byte(line), "10.1.1.1")
+	}
+	require.NoError(t, p.Stop())
+
+	// lines naming an exception may be messages of their own
+	require.Len(t, logs, 3)
+	assert.Equal(t, "request failed", logs[0].Text)
+	assert.Equal(t, "Error: connection refused", logs[1].Text)
+	assert.Equal(t, "java.lang.IllegalStateException: boom\n"+
+		"\tat com.example.Api.handle(Api.java:42)\n"+
+		"Caused by: java.io.IOException: closed\n"+
+		"\t... 3 more", logs[2].Text)
+}
+
+func TestMultilineLimits(t *testing.T) {
+	var logs []*Log
+	p, err := NewWithConfig(func(msg *Log) { logs = append(logs, msg) }, &Config{
+		Multiline: []MultilineRule{{
+			Hostname:     "host",
+			Start:        `^BEGIN`,
+			Continuation: `^\s`,
+			MaxLines:     3,
+			Timeout:      Duration(time.Nanosecond),
+		}},
+	})
+	require.NoError(t, err)
+
+	for _, line := range []string{
+		"<11>Jan 18 11:07:53 host app: BEGIN",
+		"<11>Jan 18 11:07:53 host app:  1",
+		"<11>Jan 18 11:07:53 host app:  2",
+		"<11>Jan 18 11:07:53 host app:  3",
+		"<11>Jan 18 11:07:53 host app: neither",
+		"<11>Jan 18 11:07:53 host app: BEGIN again",
+	} {
+		p.WriteLine([]byte(line), "10.1.1.1")
+	}
+
+	// lines beyond the limit start a message of their own, lines matching
+	// neither pattern are messages of their own
+	require.Len(t, logs, 3)
+	assert.Equal(t, "BEGIN\n 1\n 2", logs[0].Text)
+	assert.Equal(t, " 3", logs[1].Text)
+	assert.Equal(t, "neither", logs[2].Text)
+
+	time.Sleep(time.Millisecond)
+	require.NoError(t, p.Flush())
+	require.Len(t, logs, 4)
+	assert.Equal(t, "BEGIN again", logs[3].Text)
+
+	for _, rule := range []MultilineRule{
+		{Start: "^x"},
+		{Application: "app", Start: "("},
+		{Application: "app", Continuation: "("},
+		{Application: "app", MaxLines: -1},
+		{Hostname: "["},
+	} {
+		_, err := NewWithConfig(func(*Log) {}, &Config{Multiline: []MultilineRule{rule}})
+		assert.Error(t, err, rule)
+	}
+}
+
+func TestMultilineRaw(t *testing.T) {
+	var logs []*Log
+	p, err := NewWithConfig(func(msg *Log) { logs = append(logs, msg) }, &Config{
+		Multiline: []MultilineRule{{Application: "java"}},
+		KeepRaw:   true,
+	})
+	require.NoError(t, err)
+
+	lines := []string{
+		"<11>Jan 18 11:07:53 host java[42]: java.lang.IllegalStateException: boom",
+		"<11>Jan 18 11:07:53 host java[42]: \tat com.example.Api.handle(Api.java:42)",
+	}
+	for _, line := range lines {
+		p.WriteLine([]byte(line), "10.1.1.1")
+	}
+	require.NoError(t, p.Stop())
+	require.Len(t, logs, 1)
+	assert.Equal(t, lines[0]+"\n"+lines[1], logs[0].Raw)
+}
+
+func TestMultilineMaxPending(t *testing.T) {
+	var logs []*Log
+	p, err := NewWithConfig(func(msg *Log) { logs = append(logs, msg) }, &Config{
+		Multiline:           []MultilineRule{{Application: "java"}},
+		MaxPendingMultiline: 2,
+	})
+	require.NoError(t, err)
+
+	write := func(pid, text string) {
+		p.WriteLine([]byte("<11>Jan 18 11:07:53 host java["+pid+"]: "+text), "10.1.1.1")
+	}
+	write("1", "one")
+	write("2", "two")
+	assert.Empty(t, logs)
+
+	// the message continued the longest time ago makes room
+	write("3", "three")
+	require.Len(t, logs, 1)
+	assert.Equal(t, "one", logs[0].Text)
+
+	write("2", "\tat more")
+	write("4", "four")
+	require.Len(t, logs, 2)
+	assert.Equal(t, "three", logs[1].Text)
+
+	require.NoError(t, p.Stop())
+	require.Len(t, logs, 4)
+	assert.Equal(t, "two\n\tat more", logs[2].Text)
+	assert.Equal(t, "four", logs[3].Text)
+
+	_, err = NewWithConfig(func(*Log) {}, &Config{MaxPendingMultiline: -1})
+	assert.Error(t, err)
+}
@@ -0,0 +1,89 @@
+package parser
+
+import (
+	"regexp"
+	"strings"
+)
+
+const (
+	// netfilterFieldPrefix namespaces the netfilter fields in Log.Metadata.
+	netfilterFieldPrefix = "netfilter."
+
+	netfilterPrefix = netfilterFieldPrefix + "prefix"
+	netfilterFlags  = netfilterFieldPrefix + "flags"
+	netfilterOpt    = netfilterFieldPrefix + "opt"
+)
+
+var (
+	// netfilterRegex matches the start of the packet details the LOG and
+	// NFLOG targets of iptables and nftables write.
+	netfilterRegex = regexp.MustCompile(`(?:^|\s)IN=\S* OUT=`)
+	// kernelTimeRegex matches the uptime the kernel may put in front of its
+	// messages.
+	kernelTimeRegex = regexp.MustCompile(`^\[\s*\d+\.\d+\]\s*`)
+)
+
+// parseNetfilter parses the packets logged by netfilter:
+//
+//	[UFW BLOCK] IN=eth0 OUT= MAC=... SRC=1.2.3.4 DST=10.0.0.1 LEN=60 ... DF PROTO=TCP SPT=51234 DPT=22 ... SYN URGP=0
+//
+// The log prefix, usually the name of the rule, comes first. The pairs are
+// stored under their lowercased keys, where the first of a repeated key wins,
+// and the bare flags as a list.
+func parseNetfilter(msg *Log) bool {
+	loc := netfilterRegex.FindStringIndex(msg.Text)
+	if loc == nil {
+		return false
+	}
+
+	prefix := kernelTimeRegex.ReplaceAllString(msg.Text[:loc[0]], "")
+	prefix = strings.TrimSuffix(strings.TrimSpace(prefix), ":")
+	if strings.HasPrefix(prefix, "[") && strings.HasSuffix(prefix, "]") {
+		prefix = prefix[1 : len(prefix)-1]
+	}
+	if prefix = strings.TrimSpace(prefix); prefix != "" {
+		msg.Metadata[netfilterPrefix] = prefix
+	}
+
+	var flags []string
+	tokens := strings.Fields(msg.Text[loc[0]:])
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+		if strings.HasPrefix(token, "[") {
+			// the packet an ICMP error refers to
+			for ; i < len(tokens); i++ {
+				key, _, _ := strings.Cut(strings.TrimPrefix(tokens[i], "["), "=")
+				delete(msg.Metadata, key)
+				if strings.HasSuffix(tokens[i], "]") {
+					break
+				}
+			}
+			continue
+		}
+		if token == "OPT" && i+1 < len(tokens) && strings.HasPrefix(tokens[i+1], "(") {
+			// the TCP or IP options, `OPT (020405B4...)`
+			msg.Metadata[netfilterOpt] = strings.Trim(tokens[i+1], "()")
+			i++
+			continue
+		}
+
+		key, value, ok := strings.Cut(token, "=")
+		if !ok {
+			flags = append(flags, token)
+			continue
+		}
+
+		// the metadata parser got these already, untyped
+		delete(msg.Metadata, key)
+		field := netfilterFieldPrefix + strings.ToLower(key)
+		if _, ok := msg.Metadata[field]; ok || value == "" {
+			continue
+		}
+		msg.Metadata[field] = intOrString(value)
+	}
+	if len(flags) > 0 {
+		msg.Metadata[netfilterFlags] = flags
+	}
+
+	return true
+}
@@ -0,0 +1,85 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseNetfilter(t *testing.T) {
+	cases := []struct {
+		raw      string
+		metadata map[string]any
+	}{
+		{
+			raw: "<4>Jan 18 11:07:53 fw kernel: [12345.678901] [UFW BLOCK] IN=eth0 OUT= MAC=00:11:22:33:44:55:66:77:88:99:aa:bb:08:00 SRC=1.2.3.4 DST=10.0.0.1 LEN=60 TOS=0x00 PREC=0x00 TTL=52 ID=12345 DF PROTO=TCP SPT=51234 DPT=22 WINDOW=64240 RES=0x00 SYN URGP=0",
+			metadata: map[string]any{
+				"netfilter.prefix": "UFW BLOCK",
+				"netfilter.in":     "eth0",
+				"netfilter.mac":    "00:11:22:33:44:55:66:77:88:99:aa:bb:08:00",
+				"netfilter.src":    "1.2.3.4",
+				"netfilter.dst":    "10.0.0.1",
+				"netfilter.len":    int64(60),
+				"netfilter.tos":    "0x00",
+				"netfilter.prec":   "0x00",
+				"netfilter.ttl":    int64(52),
+				"netfilter.id":     int64(12345),
+				"netfilter.proto":  "TCP",
+				"netfilter.spt":    int64(51234),
+				"netfilter.dpt":    int64(22),
+				"netfilter.window": int64(64240),
+				"netfilter.res":    "0x00",
+				"netfilter.urgp":   int64(0),
+				"netfilter.flags":  []string{"DF", "SYN"},
+			},
+		},
+		{
+			raw: "<4>Jan 18 11:07:53 fw kernel: nft-drop: IN=eth0 OUT=eth1 SRC=10.0.0.2 DST=198.51.100.7 LEN=40 TOS=0x00 PREC=0x00 TTL=63 ID=0 DF PROTO=TCP SPT=443 DPT=51234 WINDOW=0 RES=0x00 ACK RST URGP=0 OPT (020405B4) MARK=0x1",
+			metadata: map[string]any{
+				"netfilter.prefix": "nft-drop",
+				"netfilter.in":     "eth0",
+				"netfilter.out":    "eth1",
+				"netfilter.src":    "10.0.0.2",
+				"netfilter.dst":    "198.51.100.7",
+				"netfilter.len":    int64(40),
+				"netfilter.tos":    "0x00",
+				"netfilter.prec":   "0x00",
+				"netfilter.ttl":    int64(63),
+				"netfilter.id":     int64(0),
+				"netfilter.proto":  "TCP",
+				"netfilter.spt":    int64(443),
+				"netfilter.dpt":    int64(51234),
+				"netfilter.window": int64(0),
+				"netfilter.res":    "0x00",
+				"netfilter.urgp":   int64(0),
+				"netfilter.opt":    "020405B4",
+				"netfilter.mark":   "0x1",
+				"netfilter.flags":  []string{"DF", "ACK", "RST"},
+			},
+		},
+		{
+			// the quoted packet of ICMP errors is skipped
+			raw: "<4>Jan 18 11:07:53 fw kernel: IN=eth0 OUT= SRC=10.0.0.1 DST=10.0.0.2 LEN=88 TTL=64 ID=7 PROTO=ICMP TYPE=3 CODE=3 [SRC=10.0.0.2 DST=10.0.0.1 LEN=60 TTL=64 ID=1 PROTO=UDP SPT=53 DPT=5353 LEN=40 ] MTU=1500",
+			metadata: map[string]any{
+				"netfilter.in":    "eth0",
+				"netfilter.src":   "10.0.0.1",
+				"netfilter.dst":   "10.0.0.2",
+				"netfilter.len":   int64(88),
+				"netfilter.ttl":   int64(64),
+				"netfilter.id":    int64(7),
+				"netfilter.proto": "ICMP",
+				"netfilter.type":  int64(3),
+				"netfilter.code":  int64(3),
+				"netfilter.mtu":   int64(1500),
+			},
+		},
+	}
+
+	for _, c := range cases {
+		msg := ParseLineWithFallback([]byte(c.raw), "10.1.1.1")
+		require.NotNil(t, msg, c.raw)
+		assert.Equal(t, "kernel", msg.Application, c.raw)
+		assert.Equal(t, c.metadata, msg.Metadata, c.raw)
+	}
+}
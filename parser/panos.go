@@ -0,0 +1,183 @@
+package parser
+
+import (
+	"encoding/csv"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// panosFieldPrefix namespaces the PAN-OS fields in Log.Metadata.
+const panosFieldPrefix = "panos."
+
+var (
+	// panosRegex matches the columns every PAN-OS log starts with: future
+	// use, receive time, serial number and log type.
+	panosRegex = regexp.MustCompile(`^\d*,\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2},[^,]*,[A-Z]+,`)
+	// panosTypeRegex matches the log type column.
+	panosTypeRegex = regexp.MustCompile(`^[A-Z]+$`)
+
+	// panosCommonFields are the columns shared by all log types.
+	panosCommonFields = []string{"", "receive_time", "serial", "type", "subtype", "", "time_generated"}
+
+	// panosFields are the columns of each log type, named as in the PAN-OS
+	// syslog field descriptions. Empty names are unused columns. Newer
+	// PAN-OS versions only ever append columns, so the logs of older
+	// versions simply end earlier.
+	panosFields = map[string][]string{
+		"TRAFFIC": slices.Concat(panosCommonFields, []string{
+			"src", "dst", "natsrc", "natdst", "rule", "srcuser", "dstuser", "app", "vsys", "from", "to",
+			"inbound_if", "outbound_if", "logset", "", "sessionid", "repeatcnt", "sport", "dport", "natsport",
+			"natdport", "flags", "proto", "action", "bytes", "bytes_sent", "bytes_received", "packets", "start",
+			"elapsed", "category", "", "seqno", "actionflags", "srcloc", "dstloc", "", "pkts_sent",
+			"pkts_received", "session_end_reason", "dg_hier_level_1", "dg_hier_level_2", "dg_hier_level_3",
+			"dg_hier_level_4", "vsys_name", "device_name", "action_source", "src_uuid", "dst_uuid", "tunnelid",
+			"monitortag", "parent_session_id", "parent_start_time", "tunnel", "assoc_id", "chunks",
+			"chunks_sent", "chunks_received", "rule_uuid", "http2_connection", "link_change_count", "policy_id",
+			"link_switches", "sdwan_cluster", "sdwan_device_type", "sdwan_cluster_type", "sdwan_site",
+			"dynusergroup_name", "xff_ip", "src_category", "src_profile", "src_model", "src_vendor",
+			"src_osfamily", "src_osversion", "src_host", "src_mac", "dst_category", "dst_profile", "dst_model",
+			"dst_vendor", "dst_osfamily", "dst_osversion", "dst_host", "dst_mac", "container_id",
+			"pod_namespace", "pod_name", "src_edl", "dst_edl", "hostid", "serialnumber", "src_dag", "dst_dag",
+			"session_owner", "high_res_timestamp", "nssai_sst", "nssai_sd",
+		}),
+		"THREAT": slices.Concat(panosCommonFields, []string{
+			"src", "dst", "natsrc", "natdst", "rule", "srcuser", "dstuser", "app", "vsys", "from", "to",
+			"inbound_if", "outbound_if", "logset", "", "sessionid", "repeatcnt", "sport", "dport", "natsport",
+			"natdport", "flags", "proto", "action", "misc", "threatid", "category", "severity", "direction",
+			"seqno", "actionflags", "srcloc", "dstloc", "", "contenttype", "pcap_id", "filedigest", "cloud",
+			"url_idx", "user_agent", "filetype", "xff", "referer", "sender", "subject", "recipient", "reportid",
+			"dg_hier_level_1", "dg_hier_level_2", "dg_hier_level_3", "dg_hier_level_4", "vsys_name",
+			"device_name", "", "src_uuid", "dst_uuid", "http_method", "tunnel_id", "monitortag",
+			"parent_session_id", "parent_start_time", "tunnel", "thr_category", "contentver", "", "assoc_id",
+			"ppid", "http_headers", "url_category_list", "rule_uuid", "http2_connection", "dynusergroup_name",
+			"xff_ip", "src_category", "src_profile", "src_model", "src_vendor", "src_osfamily", "src_osversion",
+			"src_host", "src_mac", "dst_category", "dst_profile", "dst_model", "dst_vendor", "dst_osfamily",
+			"dst_osversion", "dst_host", "dst_mac", "container_id", "pod_namespace", "pod_name", "src_edl",
+			"dst_edl", "hostid", "serialnumber", "domain_edl", "src_dag", "dst_dag", "partial_hash",
+			"high_res_timestamp", "reason", "justification", "nssai_sst",
+		}),
+		"SYSTEM": slices.Concat(panosCommonFields, []string{
+			"vsys", "eventid", "object", "", "", "module", "severity", "opaque", "seqno", "actionflags",
+			"dg_hier_level_1", "dg_hier_level_2", "dg_hier_level_3", "dg_hier_level_4", "vsys_name",
+			"device_name", "", "", "high_res_timestamp",
+		}),
+		"CONFIG": slices.Concat(panosCommonFields, []string{
+			"host", "vsys", "cmd", "admin", "client", "result", "path", "before_change_detail",
+			"after_change_detail", "seqno", "actionflags", "dg_hier_level_1", "dg_hier_level_2",
+			"dg_hier_level_3", "dg_hier_level_4", "vsys_name", "device_name", "dg_id", "comment", "",
+			"high_res_timestamp",
+		}),
+	}
+
+	// panosNumericFields are typed as integers, everything else is a string.
+	panosNumericFields = map[string]bool{
+		"sessionid": true, "repeatcnt": true, "sport": true, "dport": true, "natsport": true, "natdport": true,
+		"bytes": true, "bytes_sent": true, "bytes_received": true, "packets": true, "elapsed": true,
+		"seqno": true, "pkts_sent": true, "pkts_received": true, "parent_session_id": true, "assoc_id": true,
+		"chunks": true, "chunks_sent": true, "chunks_received": true, "link_change_count": true,
+		"pcap_id": true, "url_idx": true, "reportid": true, "ppid": true,
+	}
+
+	// panosSeverities maps the severities of threat and system logs onto the
+	// syslog ones.
+	panosSeverities = map[string]int64{
+		"informational": Info,
+		"low":           Notice,
+		"medium":        Warning,
+		"high":          Error,
+		"critical":      Critical,
+	}
+)
+
+// isPANOS reports whether data is a PAN-OS log.
+func isPANOS(data []byte) bool {
+	return panosRegex.Match(data)
+}
+
+// parsePANOS parses the positional CSV logs of Palo Alto Networks firewalls:
+//
+//	1,2024/01/18 11:07:53,012801096514,TRAFFIC,end,2561,2024/01/18 11:07:53,10.0.0.1,198.51.100.7,...
+//
+// Columns are named according to the log type, columns past the known ones
+// are kept as `panos.column_<n>`, counting from 1.
+func parsePANOS(msg *Log) bool {
+	text := strings.TrimSpace(msg.Text)
+	if !isPANOS([]byte(text)) {
+		return false
+	}
+
+	r := csv.NewReader(strings.NewReader(text))
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	columns, err := r.Read()
+	// quotes may merge the columns the header was recognised by
+	if err != nil || len(columns) < 4 || !panosTypeRegex.MatchString(columns[3]) {
+		return false
+	}
+
+	names, ok := panosFields[columns[3]]
+	if !ok {
+		names = panosCommonFields
+	}
+
+	fields := map[string]string{}
+	for i, value := range columns {
+		var name string
+		if i < len(names) {
+			if name = names[i]; name == "" {
+				continue
+			}
+		} else {
+			name = "column_" + strconv.Itoa(i+1)
+		}
+
+		if value == "" {
+			continue
+		}
+		fields[name] = value
+
+		if panosNumericFields[name] {
+			msg.Metadata[panosFieldPrefix+name] = intOrString(value)
+		} else {
+			msg.Metadata[panosFieldPrefix+name] = value
+		}
+	}
+
+	parsePANOSTime(msg, fields)
+
+	if severity, ok := panosSeverities[fields["severity"]]; ok {
+		msg.Severity = severity
+	}
+	if deviceName := fields["device_name"]; deviceName != "" {
+		msg.Hostname = deviceName
+	}
+	if msg.Application == "" || msg.Application == syntheticApplication {
+		msg.Application = "panos"
+	}
+	if description := fields["opaque"]; description != "" {
+		msg.Text = description
+	}
+
+	return true
+}
+
+// parsePANOSTime sets the timestamp of msg from the high resolution timestamp
+// of recent PAN-OS versions, or else from the generation time, which is in
+// the local time of the firewall.
+func parsePANOSTime(msg *Log, fields map[string]string) {
+	if ts, err := time.Parse(time.RFC3339, fields["high_res_timestamp"]); err == nil {
+		msg.Timestamp = ts.UnixNano()
+		return
+	}
+
+	ts, err := time.Parse("2006/01/02 15:04:05", fields["time_generated"])
+	if err != nil {
+		return
+	}
+	msg.wallClock = ts
+	msg.hasWallClock = true
+	msg.Timestamp = fromWallClock(ts, time.Local, time.Now()).UnixNano()
+}
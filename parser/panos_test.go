@@ -0,0 +1,153 @@
+package parser
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePANOS(t *testing.T) {
+	cases := []struct {
+		raw         string
+		hostname    string
+		application string
+		text        string
+		severity    int64
+		time        time.Time
+		metadata    map[string]any
+		missing     []string
+	}{
+		{
+			raw:         `<14>Jan 18 11:07:53 fw01 1,2024/01/18 11:07:53,012801096514,TRAFFIC,end,2561,2024/01/18 11:07:52,10.0.0.1,198.51.100.7,203.0.113.5,198.51.100.7,allow-web,corp\alice,,ssl,vsys1,trust,untrust,ethernet1/2,ethernet1/1,Forward,,12345,1,51234,443,40000,443,0x400019,tcp,allow,5678,1234,4444,20,2024/01/18 11:07:40,10,computer-and-internet-info,,7061234,0x0,10.0.0.0-10.255.255.255,United States,,12,8,tcp-fin,0,0,0,0,,PA-VM,from-policy`,
+			hostname:    "PA-VM",
+			application: "panos",
+			severity:    Info,
+			time:        time.Date(2024, 1, 18, 11, 7, 52, 0, time.Local),
+			metadata: map[string]any{
+				"panos.receive_time":       "2024/01/18 11:07:53",
+				"panos.serial":             "012801096514",
+				"panos.type":               "TRAFFIC",
+				"panos.subtype":            "end",
+				"panos.src":                "10.0.0.1",
+				"panos.dst":                "198.51.100.7",
+				"panos.natsrc":             "203.0.113.5",
+				"panos.rule":               "allow-web",
+				"panos.srcuser":            `corp\alice`,
+				"panos.app":                "ssl",
+				"panos.from":               "trust",
+				"panos.to":                 "untrust",
+				"panos.sessionid":          int64(12345),
+				"panos.sport":              int64(51234),
+				"panos.dport":              int64(443),
+				"panos.flags":              "0x400019",
+				"panos.proto":              "tcp",
+				"panos.action":             "allow",
+				"panos.bytes":              int64(5678),
+				"panos.bytes_sent":         int64(1234),
+				"panos.bytes_received":     int64(4444),
+				"panos.srcloc":             "10.0.0.0-10.255.255.255",
+				"panos.dstloc":             "United States",
+				"panos.session_end_reason": "tcp-fin",
+				"panos.dg_hier_level_1":    "0",
+				"panos.device_name":        "PA-VM",
+				"panos.action_source":      "from-policy",
+			},
+			missing: []string{"panos.dstuser", "panos.vsys_name", "panos.high_res_timestamp"},
+		},
+		{
+			raw:         `<14>Jan 18 11:07:53 fw01 1,2024/01/18 11:07:53,012801096514,THREAT,url,2561,2024/01/18 11:07:52,10.0.0.1,198.51.100.7,,,allow-web,,,web-browsing,vsys1,trust,untrust,ethernet1/2,ethernet1/1,Forward,,12346,1,51235,80,0,0,0x8000,tcp,block-url,"example.com/a,b",(9999),malware,high,client-to-server,7061235`,
+			hostname:    "fw01",
+			application: "panos",
+			severity:    Error,
+			metadata: map[string]any{
+				"panos.type":      "THREAT",
+				"panos.subtype":   "url",
+				"panos.action":    "block-url",
+				"panos.misc":      "example.com/a,b",
+				"panos.threatid":  "(9999)",
+				"panos.category":  "malware",
+				"panos.severity":  "high",
+				"panos.direction": "client-to-server",
+				"panos.seqno":     int64(7061235),
+			},
+		},
+		{
+			raw:         `1,2024/01/18 11:07:53,012801096514,SYSTEM,general,2561,2024/01/18 11:07:52,,general,,0,0,general,medium,"User admin logged in via Web from 10.0.0.2 using https, session 42",7061236,0x0,0,0,0,0,,PA-VM,0,0,2024-01-18T11:07:52.123+01:00`,
+			hostname:    "PA-VM",
+			application: "panos",
+			text:        "User admin logged in via Web from 10.0.0.2 using https, session 42",
+			severity:    Warning,
+			time:        time.Date(2024, 1, 18, 10, 7, 52, 123000000, time.UTC),
+			metadata: map[string]any{
+				"panos.type":               "SYSTEM",
+				"panos.eventid":            "general",
+				"panos.module":             "general",
+				"panos.severity":           "medium",
+				"panos.opaque":             "User admin logged in via Web from 10.0.0.2 using https, session 42",
+				"panos.seqno":              int64(7061236),
+				"panos.high_res_timestamp": "2024-01-18T11:07:52.123+01:00",
+			},
+		},
+		{
+			// columns of newer versions than known are kept by position
+			raw:         `1,2024/01/18 11:07:53,012801096514,CONFIG,0,2561,2024/01/18 11:07:52,10.0.0.2,vsys1,set,admin,Web,Succeeded,deviceconfig system,,,7061237,0x0,0,0,0,0,,PA-VM,,,,,extra,42`,
+			hostname:    "PA-VM",
+			application: "panos",
+			severity:    Info,
+			metadata: map[string]any{
+				"panos.type":      "CONFIG",
+				"panos.host":      "10.0.0.2",
+				"panos.cmd":       "set",
+				"panos.admin":     "admin",
+				"panos.client":    "Web",
+				"panos.result":    "Succeeded",
+				"panos.path":      "deviceconfig system",
+				"panos.column_29": "extra",
+				"panos.column_30": "42",
+			},
+		},
+		{
+			// unknown log types keep the common columns
+			raw:         `1,2024/01/18 11:07:53,012801096514,GLOBALPROTECT,0,2561,2024/01/18 11:07:52,vsys1,gateway-auth`,
+			application: "panos",
+			severity:    Info,
+			metadata: map[string]any{
+				"panos.type":     "GLOBALPROTECT",
+				"panos.column_8": "vsys1",
+				"panos.column_9": "gateway-auth",
+			},
+		},
+	}
+
+	for _, c := range cases {
+		msg := ParseLineWithFallback([]byte(c.raw), "10.1.1.1")
+		require.NotNil(t, msg, c.raw)
+
+		if c.hostname != "" {
+			assert.Equal(t, c.hostname, msg.Hostname, c.raw)
+		}
+		if c.text != "" {
+			assert.Equal(t, c.text, msg.Text, c.raw)
+		}
+		if !c.time.IsZero() {
+			assert.Equal(t, c.time.UnixNano(), msg.Timestamp, c.raw)
+		}
+		assert.Equal(t, c.application, msg.Application, c.raw)
+		assert.Equal(t, c.severity, msg.Severity, c.raw)
+		for k, v := range c.metadata {
+			assert.Equal(t, v, msg.Metadata[k], "%s: %s", k, c.raw)
+		}
+		for _, k := range c.missing {
+			assert.NotContains(t, msg.Metadata, k, c.raw)
+		}
+	}
+
+	// quotes merging the header columns leave the message alone
+	const raw = `<14>Jan 18 11:07:53 host fw: 1,2024/01/18 11:07:53,"000,AAAAAAA,0000000000000000000000000000000000000000000`
+	msg := ParseLineWithFallback([]byte(raw), "10.1.1.1")
+	require.NotNil(t, msg)
+	assert.Equal(t, "fw", msg.Application)
+	assert.Empty(t, msg.Metadata)
+}
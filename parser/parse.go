@@ -2,11 +2,13 @@ package parser
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"log"
 	"regexp"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/buger/jsonparser"
 )
@@ -14,31 +16,51 @@ import (
 const (
 	logfileKey   = "axiom.logfile"
 	maxNestLevel = 5
+	// jsonPrefixKey holds the text before a JSON object that ends a message.
+	jsonPrefixKey = "json.prefix"
+	// jsonRemainderKey holds the fields of a JSON object that are beyond
+	// MaxJSONFields, as a JSON object.
+	jsonRemainderKey = "json.remainder"
+	// defaultMaxJSONFields is the number of fields a message gets from JSON
+	// in nested mode.
+	defaultMaxJSONFields = 1000
+	// syntheticApplication is the application of messages without a valid
+	// syslog header.
+	syntheticApplication = "unknown"
 )
 
 var (
-	timestampKeys = map[string]bool{"syslog.timestamp": true, "timestamp": true, "eventtime": true, "@timestamp": true, "_timestamp": true, "date": true, "published_date": true}
-	hostKeys      = map[string]bool{"syslog.hostname": true, "hostname": true, "host": true}
-	appKeys       = map[string]bool{"syslog.appname": true, "app": true, "application": true}
-	msgKeys       = map[string]bool{"message": true, "msg": true}
-	severityKeys  = map[string]bool{"syslog.severity": true, "severity": true, "status": true, "level": true}
 	jsonKeysRegex = regexp.MustCompile(`[\.\[\]]`)
 )
 
 // ParseLineWithFallback parses an individual line, and creates a message if the line is not valid
 func ParseLineWithFallback(line []byte, remoteAddr string) *Log {
+	return parseLineWithFallback(line, remoteAddr, defaultConfig)
+}
+
+func parseLineWithFallback(line []byte, remoteAddr string, config *Config) *Log {
+	m := parseLine(line, remoteAddr, config)
+	if m != nil {
+		finishLog(m, config)
+	}
+	return m
+}
+
+// parseLine parses everything up to the message text, which may be only part
+// of a message that still needs to be reassembled.
+func parseLine(line []byte, remoteAddr string, config *Config) *Log {
 	var m *Log
 	var err error
 
 	if ok, jsonMsg := detectMaybeJSON(line); ok {
-		m, err = parseJSON(jsonMsg)
+		m, err = parseJSON(jsonMsg, config, "")
 		// if the message is not valid json, fallback to syslog
 		if err != nil {
 			log.Printf("Unable to parse log line, err=%q: %s", err, line)
-			m, err = parseSyslogLine(line)
+			m, err = parseSyslogLine(line, config)
 		}
 	} else {
-		m, err = parseSyslogLine(line)
+		m, err = parseSyslogLine(line, config)
 	}
 
 	if err != nil {
@@ -50,13 +72,21 @@ func ParseLineWithFallback(line []byte, remoteAddr string) *Log {
 		if len(line) < 1 {
 			return nil
 		}
-		if m, err = syntheticLog(remoteAddr, line); err != nil {
+		if m, err = syntheticLog(remoteAddr, line, config); err != nil {
 			return nil
 		}
 	}
 
 	m.RemoteAddr = remoteAddr
 
+	if config.KeepRaw {
+		m.Raw = truncateRaw(string(line), config.MaxRawSize)
+	}
+
+	if config.hostnames != nil && remoteAddr != "" {
+		m.ResolvedHostname, _ = config.hostnames.lookup(remoteAddr)
+	}
+
 	if m.Hostname == "" {
 		m.Hostname = remoteAddr
 	}
@@ -65,21 +95,72 @@ func ParseLineWithFallback(line []byte, remoteAddr string) *Log {
 		m.Timestamp = time.Now().UnixNano()
 	}
 
-	parseApp(m)
+	// container runtimes split lines, which needs to be known before they are
+	// reassembled
+	runAppParsers(m, config, StageLine)
+
+	return m
+}
+
+// truncateRaw cuts raw to at most max bytes, at the start of a rune. Zero
+// means no limit.
+func truncateRaw(raw string, max int) string {
+	if max <= 0 || len(raw) <= max {
+		return raw
+	}
+	i := max
+	for i > 0 && !utf8.RuneStart(raw[i]) {
+		i--
+	}
+	return raw[:i]
+}
+
+// joinRaw appends the raw line of a message continuing another to the raw
+// lines before it, as far as they fit in max bytes.
+func joinRaw(raw, line string, max int) string {
+	if raw == "" || line == "" || max > 0 && len(raw) >= max {
+		return raw
+	}
+	return truncateRaw(raw+"\n"+line, max)
+}
+
+// finishLog parses the complete message text.
+func finishLog(m *Log, config *Config) {
+	parseApp(m, config)
 
 	// attempt to parse json from the text property
 	if ok, msg := detectMaybeJSON([]byte(m.Text)); ok {
-		sublog, err := parseJSON(msg)
+		sublog, err := parseJSON(msg, config, m.Application)
 		if err == nil {
 			// merge the sublog with the main log
 			m.Merge(sublog)
 		}
+	} else if config.JSONAfterPrefix {
+		if prefix, msg, ok := detectJSONAfterPrefix(m.Text); ok {
+			if sublog, err := parseJSON(msg, config, m.Application); err == nil {
+				// the prefix is the text, unless the json has one of its own
+				if sublog.Text == "" {
+					sublog.Text = prefix
+				} else {
+					sublog.Metadata[jsonPrefixKey] = prefix
+				}
+				m.Merge(sublog)
+			}
+		}
+	}
+
+	// formats recognised by their fields, like the Windows events NXLog
+	// forwards as JSON or as metadata
+	runAppParsers(m, config, StageJSON)
+
+	if m.hasWallClock {
+		if loc := config.timeZone(m.RemoteAddr, m.Hostname); loc != time.Local {
+			m.Timestamp = fromWallClock(m.wallClock, loc, time.Now()).UnixNano()
+		}
 	}
 
 	// Always last
 	populateSeverity(m)
-
-	return m
 }
 
 // detectMaybeJSON finds hints of a json message but does not
@@ -140,56 +221,216 @@ func detectMaybeJSON(line []byte) (ok bool, result []byte) {
 	return
 }
 
-// parseJSON takes a single json message to parse
-func parseJSON(data []byte) (*Log, error) {
+// detectJSONAfterPrefix finds a json object at the end of text that follows
+// some other text, like `app: {...}` or `2024-01-01 INFO {...}`, and returns
+// the text before it. Like detectMaybeJSON, it doesn't guarantee the object
+// is valid json.
+func detectJSONAfterPrefix(text string) (prefix string, result []byte, ok bool) {
+	text = strings.TrimRight(text, " ")
+	if !strings.HasSuffix(text, "}") {
+		return "", nil, false
+	}
+
+	// the object starts at the first brace after a space whose object only
+	// closes at the end of the text
+	for i := 1; i < len(text); i++ {
+		if text[i] != '{' || text[i-1] != ' ' {
+			continue
+		}
+		end := jsonObjectEnd(text[i:])
+		if end < 0 {
+			continue
+		}
+		if i+end == len(text) {
+			if prefix = strings.TrimSpace(text[:i]); prefix == "" {
+				return "", nil, false
+			}
+			return prefix, []byte(text[i:]), true
+		}
+		// an object within the prefix
+		i += end - 1
+	}
+	return "", nil, false
+}
+
+// jsonObjectEnd returns the index after the brace closing the object s
+// starts with, or -1 if it isn't closed.
+func jsonObjectEnd(s string) int {
+	depth := 0
+	inString := false
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case inString && c == '\\':
+			i++
+		case c == '"':
+			inString = !inString
+		case inString:
+		case c == '{' || c == '[':
+			depth++
+		case c == '}' || c == ']':
+			if depth--; depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return -1
+}
+
+// parseJSON takes a single json message to parse. The fields holding the
+// timestamp, hostname and so on are the ones configured for application, or
+// for the application in the message if it is empty.
+func parseJSON(data []byte, config *Config, application string) (*Log, error) {
 	msg := &Log{
 		Metadata: map[string]any{},
 	}
 
+	mapping := config.jsonFieldsFor(application)
+	found, err := mapping.find(data)
+	if err != nil {
+		return nil, err
+	}
+	if v := found[jsonApplication]; application == "" && v.ok {
+		if app, err := jsonparser.ParseString(v.value); err == nil && config.jsonFieldsFor(app) != mapping {
+			mapping = config.jsonFieldsFor(app)
+			if found, err = mapping.find(data); err != nil {
+				return nil, err
+			}
+			if !found[jsonApplication].ok {
+				found[jsonApplication] = v
+			}
+		}
+	}
+
+	// the timestamp is parsed once the whole message is read, as the layouts
+	// to try depend on the application
+	var timestamp string
+	for field := range found {
+		if found[field].ok {
+			if err := setJSONField(msg, jsonField(field), &found[field], &timestamp); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// the fields used are left out of the metadata
+	fields := orDefault(config.MaxJSONFields, defaultMaxJSONFields)
+	var remainder []byte
 	if err := jsonparser.ObjectEach(data, func(key []byte, value []byte, dataType jsonparser.ValueType, _ int) error {
-		return extractJSONProperty(key, value, dataType, msg)
+		var paths [][]string
+		for _, v := range found {
+			if v.ok && bytes.Equal(v.key, key) {
+				if v.path == nil {
+					return nil
+				}
+				paths = append(paths, v.path)
+			}
+		}
+		if paths != nil {
+			var ok bool
+			if value, ok = withoutJSONPaths(value, paths); !ok {
+				return nil
+			}
+		}
+		return extractMetadata(key, value, dataType, msg, config, &fields, &remainder)
 	}); err != nil {
 		return nil, err
 	}
+	if remainder != nil {
+		msg.Metadata[jsonRemainderKey] = string(append(remainder, '}'))
+	}
 
-	return msg, nil
-}
-
-func extractJSONProperty(key []byte, value []byte, dataType jsonparser.ValueType, msg *Log) error {
-	loweredString := strings.ToLower(string(key))
-	if dataType == jsonparser.String {
-		stringValue, parseErr := jsonparser.ParseString(value)
-		if parseErr != nil {
-			return parseErr
+	if timestamp != "" {
+		app := application
+		if app == "" {
+			app = msg.Application
 		}
-		if timestampKeys[loweredString] {
-			if t, e := time.Parse(time.RFC3339Nano, stringValue); e == nil {
-				msg.Timestamp = t.UnixNano()
-			} else {
-				msg.Metadata["unparsed_timestamp"] = stringValue
+		if ts, zoneless, ok := parseTimestamp(timestamp, config.layoutsFor(app)); ok {
+			if zoneless {
+				msg.wallClock = ts
+				msg.hasWallClock = true
+				ts = fromWallClock(ts, time.Local, time.Now())
 			}
-		} else if hostKeys[loweredString] {
-			msg.Hostname = stringValue
-		} else if appKeys[loweredString] {
-			msg.Application = stringValue
-		} else if msgKeys[loweredString] {
-			msg.Text = stringValue
-		} else if severityKeys[loweredString] {
-			msg.Severity = int64(SeverityFromString(string(value)))
+			msg.Timestamp = ts.UnixNano()
 		} else {
-			if err := extractMetadataValue(joinKey("", string(key)), value, dataType, 0, msg); err != nil {
-				return err
-			}
+			msg.Metadata["unparsed_timestamp"] = timestamp
 		}
-	} else {
-		if err := extractMetadataValue(joinKey("", string(key)), value, dataType, 0, msg); err != nil {
-			return err
+	}
+
+	return msg, nil
+}
+
+// setJSONField sets field of msg to the JSON value v.
+func setJSONField(msg *Log, field jsonField, v *jsonValue, timestamp *string) error {
+	if v.dataType == jsonparser.Number {
+		switch field {
+		case jsonTimestamp:
+			*timestamp = string(v.value)
+		case jsonSeverity:
+			level, _ := ParseInt(v.value)
+			msg.Severity, _ = severityFromLevel(level)
 		}
+		return nil
+	}
+
+	stringValue, err := jsonparser.ParseString(v.value)
+	if err != nil {
+		return err
+	}
+	switch field {
+	case jsonTimestamp:
+		*timestamp = stringValue
+	case jsonHostname:
+		msg.Hostname = stringValue
+	case jsonApplication:
+		msg.Application = stringValue
+	case jsonMessage:
+		msg.Text = stringValue
+	case jsonSeverity:
+		msg.Severity = int64(SeverityFromString(string(v.value)))
 	}
 	return nil
 }
 
-func extractMetadataValue(concatKey string, value []byte, dataType jsonparser.ValueType, level int64, msg *Log) error {
+// withoutJSONPaths returns the object value without the values at paths, or
+// false if nothing is left of it.
+func withoutJSONPaths(value []byte, paths [][]string) ([]byte, bool) {
+	value = append([]byte(nil), value...)
+	for _, path := range paths {
+		value = jsonparser.Delete(value, path...)
+	}
+
+	left := false
+	_ = jsonparser.ObjectEach(value, func([]byte, []byte, jsonparser.ValueType, int) error {
+		left = true
+		return nil
+	})
+	return value, left
+}
+
+// extractMetadata adds a top-level field of a JSON object to the metadata. In
+// nested mode, the fields beyond the number left are appended to remainder
+// instead, as the members of a JSON object.
+func extractMetadata(key []byte, value []byte, dataType jsonparser.ValueType, msg *Log, config *Config, fields *int, remainder *[]byte) error {
+	if !config.NestedJSON {
+		return extractMetadataValue(joinKey("", string(key)), value, dataType, 0, msg, config)
+	}
+
+	if *fields <= 0 {
+		*remainder = appendJSONMember(*remainder, key, value, dataType)
+		return nil
+	}
+	*fields--
+	v, ok, err := nestedMetadataValue(value, dataType, 1, config, fields)
+	if err != nil {
+		return err
+	}
+	if ok {
+		msg.Metadata[string(key)] = v
+	}
+	return nil
+}
+
+func extractMetadataValue(concatKey string, value []byte, dataType jsonparser.ValueType, level int64, msg *Log, config *Config) error {
 	if level > maxNestLevel {
 		return nil
 	}
@@ -198,7 +439,7 @@ func extractMetadataValue(concatKey string, value []byte, dataType jsonparser.Va
 	case jsonparser.Object:
 		level++
 		if err := jsonparser.ObjectEach(value, func(kk []byte, vv []byte, dtdt jsonparser.ValueType, _ int) error {
-			return extractMetadataValue(joinKey(concatKey, string(kk)), vv, dtdt, level, msg)
+			return extractMetadataValue(joinKey(concatKey, string(kk)), vv, dtdt, level, msg, config)
 		}); err != nil {
 			return err
 		}
@@ -210,33 +451,124 @@ func extractMetadataValue(concatKey string, value []byte, dataType jsonparser.Va
 				return
 			}
 			newConcatKey := fmt.Sprintf("%s[%d]", concatKey, arrayIndex)
-			if err := extractMetadataValue(newConcatKey, vv, dtdt, level, msg); err != nil {
+			if err := extractMetadataValue(newConcatKey, vv, dtdt, level, msg, config); err != nil {
 				return
 			}
 			arrayIndex++
 		}); err != nil {
 			return err
 		}
-	case jsonparser.Number:
-		if n, err := ParseInt(value); err == nil {
-			msg.Metadata[concatKey] = n
-		} else if f, err := ParseFloat(value); err == nil {
-			msg.Metadata[concatKey] = f
+	default:
+		v, ok, err := scalarMetadataValue(value, dataType, config)
+		if err != nil {
+			return err
 		}
+		if ok {
+			msg.Metadata[concatKey] = v
+		}
+	}
+	return nil
+}
+
+// appendJSONMember appends the member key of a JSON object to members, which
+// is the start of the object. The key and string values are still escaped.
+func appendJSONMember(members []byte, key []byte, value []byte, dataType jsonparser.ValueType) []byte {
+	if members == nil {
+		members = append(members, '{')
+	} else {
+		members = append(members, ',')
+	}
+	members = append(append(append(members, '"'), key...), `":`...)
+	if dataType == jsonparser.String {
+		return append(append(append(members, '"'), value...), '"')
+	}
+	return append(members, value...)
+}
+
+// errJSONFieldLimit stops decoding an object that doesn't fit in the number
+// of fields left.
+var errJSONFieldLimit = errors.New("too many JSON fields")
+
+// nestedMetadataValue returns the value of a JSON field, with objects and
+// arrays as maps and slices. Objects and arrays nested deeper than
+// MaxJSONDepth, or with more fields than are left, are kept as their JSON
+// text. Nulls in arrays are always kept, so elements keep their positions.
+func nestedMetadataValue(value []byte, dataType jsonparser.ValueType, level int, config *Config, fields *int) (any, bool, error) {
+	if dataType != jsonparser.Object && dataType != jsonparser.Array {
+		return scalarMetadataValue(value, dataType, config)
+	}
+	if level > orDefault(config.MaxJSONDepth, maxNestLevel) {
+		return string(value), true, nil
+	}
+
+	left := *fields
+	switch dataType {
+	case jsonparser.Object:
+		object := map[string]any{}
+		err := jsonparser.ObjectEach(value, func(kk []byte, vv []byte, dtdt jsonparser.ValueType, _ int) error {
+			if *fields--; *fields < 0 {
+				return errJSONFieldLimit
+			}
+			v, ok, err := nestedMetadataValue(vv, dtdt, level+1, config, fields)
+			if err != nil {
+				return err
+			}
+			if ok {
+				object[string(kk)] = v
+			}
+			return nil
+		})
+		if err == errJSONFieldLimit {
+			*fields = left
+			return string(value), true, nil
+		}
+		return object, err == nil, err
+	default:
+		array := []any{}
+		var err error
+		if _, arrayErr := jsonparser.ArrayEach(value, func(vv []byte, dtdt jsonparser.ValueType, _ int, _ error) {
+			if err != nil {
+				return
+			}
+			if *fields--; *fields < 0 {
+				err = errJSONFieldLimit
+				return
+			}
+			var v any
+			v, _, err = nestedMetadataValue(vv, dtdt, level+1, config, fields)
+			array = append(array, v)
+		}); arrayErr != nil {
+			return nil, false, arrayErr
+		}
+		if err == errJSONFieldLimit {
+			*fields = left
+			return string(value), true, nil
+		}
+		return array, err == nil, err
+	}
+}
+
+// scalarMetadataValue returns the value of a JSON string, number, boolean or
+// null, and whether it is stored. Nulls are only stored with
+// Config.KeepNulls.
+func scalarMetadataValue(value []byte, dataType jsonparser.ValueType, config *Config) (any, bool, error) {
+	switch dataType {
+	case jsonparser.Number:
+		n, ok := numberValue(value)
+		return n, ok, nil
 	case jsonparser.Boolean:
-		msg.Metadata[concatKey] = string(value)
+		return value[0] == 't', true, nil
 	case jsonparser.String:
-		stringValue, parseErr := jsonparser.ParseString(value)
-		if parseErr != nil {
-			return parseErr
-		}
-		msg.Metadata[concatKey] = stringValue
-	case jsonparser.NotExist, jsonparser.Null, jsonparser.Unknown:
+		s, err := jsonparser.ParseString(value)
+		return s, err == nil, err
+	case jsonparser.Null:
+		return nil, config.KeepNulls, nil
+	case jsonparser.NotExist, jsonparser.Unknown:
 		fallthrough
 	default:
 		log.Printf("JSON type %v is unsupported", dataType)
 	}
-	return nil
+	return nil, false, nil
 }
 
 func joinKey(parent string, child string) string {
@@ -250,16 +582,16 @@ func joinKey(parent string, child string) string {
 }
 
 // parseSyslogLine takes a single syslog message to parse
-func parseSyslogLine(data []byte) (*Log, error) {
+func parseSyslogLine(data []byte, config *Config) (*Log, error) {
 	if bytes.IndexByte(data, '<') != 0 {
 		return nil, errParse
 	}
-	return parseSyslog(data)
+	return parseSyslog(data, config)
 }
 
-func syntheticLog(host string, msg []byte) (*Log, error) {
-	line := fmt.Sprintf("<14>%s %s %s: %s", time.Now().UTC().Format(time.RFC3339), host, "unknown", bytes.TrimSpace(msg))
-	return parseSyslogLine([]byte(line))
+func syntheticLog(host string, msg []byte, config *Config) (*Log, error) {
+	line := fmt.Sprintf("<14>%s %s %s: %s", time.Now().UTC().Format(time.RFC3339), host, syntheticApplication, bytes.TrimSpace(msg))
+	return parseSyslogLine([]byte(line), config)
 }
 
 func extractSeverity(text string) int32 {
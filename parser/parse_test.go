@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -34,6 +35,22 @@ type Case struct {
 	adjust        bool
 }
 
+// bsdDate returns the time a timestamp without a year is expected to be
+// parsed as, which is the occurrence that is at most half a year away.
+func bsdDate(month time.Month, day, hour, minute, sec, nsec int, loc *time.Location) time.Time {
+	const halfYear = 365 * 24 * time.Hour / 2
+
+	now := time.Now()
+	ts := time.Date(now.Year(), month, day, hour, minute, sec, nsec, loc)
+	switch {
+	case ts.Sub(now) > halfYear:
+		return ts.AddDate(-1, 0, 0)
+	case now.Sub(ts) > halfYear:
+		return ts.AddDate(1, 0, 0)
+	}
+	return ts
+}
+
 func (s *ParseTestSuite) TestParseJson() {
 	require := s.Require()
 	now := time.Now()
@@ -47,7 +64,7 @@ func (s *ParseTestSuite) TestParseJson() {
 			hostname:    "forwind.net",
 			text:        "Favourite album",
 			severity:    int64(Info),
-			metadata:    map[string]any{"\"annoy[ing]\"": "value", "artist": "Tomonari Nozaki", "album": "North Palace", "data[0]": int64(0), "data[1]": "one", "data[2].number": "deux", "data[3]": 3.3, "data[4]": "false"},
+			metadata:    map[string]any{"\"annoy[ing]\"": "value", "artist": "Tomonari Nozaki", "album": "North Palace", "data[0]": int64(0), "data[1]": "one", "data[2].number": "deux", "data[3]": 3.3, "data[4]": false},
 		},
 		{
 			raw:         fmt.Appendf(nil, `{"syslog.severity":"info", "oh.no": ":(", "oh": {"no[7]": ":((("}, "artist": "Fourth Page", "album": "Along the weak rope", "Msg": "Least Favourite album", "app":"logstash", "host":"forwind.net", "Timestamp": "%s"}`, nowFormatted),
@@ -65,7 +82,7 @@ func (s *ParseTestSuite) TestParseJson() {
 			hostname:    "forwind.net",
 			text:        "Best recent 1",
 			severity:    int64(Debug),
-			metadata:    map[string]any{"artist": "Rune Clausen", "album": "Tones Jul", "\"a.h[a]\".\"ta.ke\"[0]": "on", "\"a.h[a]\".\"ta.ke\"[1]": "m.e", "\"a.h[a]\".float": 4.3, "\"a.h[a]\".\"bo[ol]\"": "false"},
+			metadata:    map[string]any{"artist": "Rune Clausen", "album": "Tones Jul", "\"a.h[a]\".\"ta.ke\"[0]": "on", "\"a.h[a]\".\"ta.ke\"[1]": "m.e", "\"a.h[a]\".float": 4.3, "\"a.h[a]\".\"bo[ol]\"": false},
 		},
 		{
 			raw:         fmt.Appendf(nil, `{"level":"trace", "msg": "Best recent 2", "artist": "Rune Clausen", "album": "Tones Jul", "application":"logstash", "syslog.hostname":"forwind.net", "syslog.timestamp":"%s"}`, nowFormatted),
@@ -83,7 +100,7 @@ func (s *ParseTestSuite) TestParseJson() {
 			hostname:    "forwind.net",
 			text:        "Best recent 3",
 			severity:    int64(Trace),
-			metadata:    map[string]any{"forwind.favourites.artist": "Rune Clausen", "bool": "true", "forwind.favourites.release.link.type.origin": "home", "forwind.favourites.album": "Blindlight", "forwind.favourites.release.duration": int64(100), "forwind.favourites.release.catno": "fwd09", "forwind.favourites.release.link.url": "http://www.forwind.net"},
+			metadata:    map[string]any{"forwind.favourites.artist": "Rune Clausen", "bool": true, "forwind.favourites.release.link.type.origin": "home", "forwind.favourites.album": "Blindlight", "forwind.favourites.release.duration": int64(100), "forwind.favourites.release.catno": "fwd09", "forwind.favourites.release.link.url": "http://www.forwind.net"},
 		},
 		// JSON encoded in syslog
 		{
@@ -93,7 +110,7 @@ func (s *ParseTestSuite) TestParseJson() {
 			hostname:    "forwind.net",
 			text:        "Best recent 3",
 			severity:    int64(Trace),
-			metadata:    map[string]any{"forwind.favourites.artist": "Rune Clausen", "bool": "true", "forwind.favourites.release.link.type.origin": "home", "forwind.favourites.album": "Blindlight", "forwind.favourites.release.duration": int64(100), "forwind.favourites.release.catno": "fwd09", "forwind.favourites.release.link.url": "http://www.forwind.net"},
+			metadata:    map[string]any{"forwind.favourites.artist": "Rune Clausen", "bool": true, "forwind.favourites.release.link.type.origin": "home", "forwind.favourites.album": "Blindlight", "forwind.favourites.release.duration": int64(100), "forwind.favourites.release.catno": "fwd09", "forwind.favourites.release.link.url": "http://www.forwind.net"},
 		},
 		// JSON encoded in non valid syslog
 		{
@@ -103,25 +120,25 @@ func (s *ParseTestSuite) TestParseJson() {
 			hostname:    "forwind.net",
 			text:        "Best recent 3",
 			severity:    int64(Trace),
-			metadata:    map[string]any{"forwind.favourites.artist": "Rune Clausen", "bool": "true", "forwind.favourites.release.link.type.origin": "home", "forwind.favourites.album": "Blindlight", "forwind.favourites.release.duration": int64(100), "forwind.favourites.release.catno": "fwd09", "forwind.favourites.release.link.url": "http://www.forwind.net"},
+			metadata:    map[string]any{"forwind.favourites.artist": "Rune Clausen", "bool": true, "forwind.favourites.release.link.type.origin": "home", "forwind.favourites.album": "Blindlight", "forwind.favourites.release.duration": int64(100), "forwind.favourites.release.catno": "fwd09", "forwind.favourites.release.link.url": "http://www.forwind.net"},
 		},
 		{
 			raw:         fmt.Appendf(nil, "<34>1 %s mymachine.example.com su - ID47 - {\"level\":\"error\",\"service\":\"public-service\",\"env\":\"production\",\"error\":\"fail\",\"time\":\"2024-04-05T05:47:24Z\",\"req_id\":\"req-id\",\"message_inside\":\"this will work due to \\\" quote foobar\"}", nowFormatted),
-			time:        now,
+			time:        time.Date(2024, 4, 5, 5, 47, 24, 0, time.UTC),
 			application: "su",
 			hostname:    "mymachine.example.com",
 			text:        "{\"level\":\"error\",\"service\":\"public-service\",\"env\":\"production\",\"error\":\"fail\",\"time\":\"2024-04-05T05:47:24Z\",\"req_id\":\"req-id\",\"message_inside\":\"this will work due to \\\" quote foobar\"}",
 			severity:    int64(Error),
-			metadata:    map[string]any{"error": "fail", "time": "2024-04-05T05:47:24Z", "req_id": "req-id", "message_inside": "this will work due to \" quote foobar", "service": "public-service", "env": "production"},
+			metadata:    map[string]any{"error": "fail", "req_id": "req-id", "message_inside": "this will work due to \" quote foobar", "service": "public-service", "env": "production"},
 		},
 		{
 			raw:         fmt.Appendf(nil, "<34>1 %s mymachine.example.com su - ID47 - {\"level\":\"error\",\"service\":\"public-service\",\"env\":\"production\",\"error\":\"fail\",\"time\":\"2024-04-05T05:47:24Z\",\"req_id\":\"req-id\",\"message\":\"this will work due to \\\" quote foobar\"}", nowFormatted),
-			time:        now,
+			time:        time.Date(2024, 4, 5, 5, 47, 24, 0, time.UTC),
 			application: "su",
 			hostname:    "mymachine.example.com",
 			text:        "this will work due to \" quote foobar",
 			severity:    int64(Error),
-			metadata:    map[string]any{"service": "public-service", "env": "production", "error": "fail", "time": "2024-04-05T05:47:24Z", "req_id": "req-id"},
+			metadata:    map[string]any{"service": "public-service", "env": "production", "error": "fail", "req_id": "req-id"},
 		},
 	}
 
@@ -188,25 +205,25 @@ func (s *ParseTestSuite) TestParse() {
 			application: "src",
 			text:        `time="2018-06-02T17:16:14.392415523+01:00" bool=false level=info float=5.6 number=3 msg="[graphdriver] using prior storage driver: aufs"`,
 			imprecise:   true,
-			metadata:    map[string]any{"time": "2018-06-02T17:16:14.392415523+01:00", "bool": "false", "level": "info", "float": float64(5.6), "number": int64(3)},
+			metadata:    map[string]any{"time": "2018-06-02T17:16:14.392415523+01:00", "bool": false, "level": "info", "float": float64(5.6), "number": int64(3)},
 		},
 		{
 			raw:         []byte("<15>Jan  1 01:00:00 bzorp openvpn[2499]: PTHREAD support initialized"),
-			time:        time.Date(now.Year(), 1, 1, 1, 0, 0, 0, time.Local),
+			time:        bsdDate(1, 1, 1, 0, 0, 0, time.Local),
 			hostname:    "bzorp",
 			application: "openvpn",
 			text:        "PTHREAD support initialized",
 		},
 		{
 			raw:         []byte("<15>Jan 10 01:00:00 bzorp openvpn[2499]: PTHREAD support initialized"),
-			time:        time.Date(now.Year(), 1, 10, 1, 0, 0, 0, time.Local),
+			time:        bsdDate(1, 10, 1, 0, 0, 0, time.Local),
 			hostname:    "bzorp",
 			application: "openvpn",
 			text:        "PTHREAD support initialized",
 		},
 		{
 			raw:         []byte("<13>Jan  1 14:40:51 alma korte: message"),
-			time:        time.Date(now.Year(), 1, 1, 14, 40, 51, 0, time.Local),
+			time:        bsdDate(1, 1, 14, 40, 51, 0, time.Local),
 			hostname:    "alma",
 			application: "korte",
 			text:        "message",
@@ -326,28 +343,28 @@ func (s *ParseTestSuite) TestParse() {
 		},
 		{
 			raw:         []byte("<7> Aug 29 02:00:00.156 ctld snmpd[2499]: PTHREAD support initialized"),
-			time:        time.Date(now.Year(), 8, 29, 2, 00, 00, 156000000, time.Local),
+			time:        bsdDate(8, 29, 2, 00, 00, 156000000, time.Local),
 			hostname:    "ctld",
 			application: "snmpd",
 			text:        "PTHREAD support initialized",
 		},
 		{
 			raw:         []byte("<7> Aug 29 02:00:00. ctld snmpd[2499]: PTHREAD support initialized"),
-			time:        time.Date(now.Year(), 8, 29, 2, 00, 00, 0, time.Local),
+			time:        bsdDate(8, 29, 2, 00, 00, 0, time.Local),
 			hostname:    "ctld",
 			application: "snmpd",
 			text:        "PTHREAD support initialized",
 		},
 		{
 			raw:         []byte("<7> Aug 29 02:00:00 ctld snmpd[2499]: PTHREAD support initialized"),
-			time:        time.Date(now.Year(), 8, 29, 2, 00, 00, 0, time.Local),
+			time:        bsdDate(8, 29, 2, 00, 00, 0, time.Local),
 			hostname:    "ctld",
 			application: "snmpd",
 			text:        "PTHREAD support initialized",
 		},
 		{
 			raw:         []byte("<7>Aug 29 02:00:00 bzorp ctld/snmpd[2499]: PTHREAD support initialized"),
-			time:        time.Date(now.Year(), 8, 29, 2, 00, 00, 0, time.Local),
+			time:        bsdDate(8, 29, 2, 00, 00, 0, time.Local),
 			hostname:    "bzorp",
 			application: "ctld/snmpd",
 			text:        "PTHREAD support initialized",
@@ -356,7 +373,7 @@ func (s *ParseTestSuite) TestParse() {
 			raw:         []byte("<190>Apr 15 2007 21:28:13: %PIX-6-302014: Teardown TCP connection 1688438 for bloomberg-net:1.2.3.4/8294 to inside:5.6.7.8/3639 duration 0:07:01 bytes 16975 TCP FINs"),
 			time:        time.Date(2007, 4, 15, 21, 28, 13, 0, time.Local),
 			hostname:    "",
-			application: "%PIX-6-302014",
+			application: "PIX",
 			text:        "Teardown TCP connection 1688438 for bloomberg-net:1.2.3.4/8294 to inside:5.6.7.8/3639 duration 0:07:01 bytes 16975 TCP FINs",
 		},
 		{
@@ -367,14 +384,14 @@ func (s *ParseTestSuite) TestParse() {
 		},
 		{
 			raw:         []byte("<38>Sep 22 10:11:56 cdaix66 sshd[679960]: Accepted publickey for nagios from 1.9.1.1 port 42096 ssh2"),
-			time:        time.Date(now.Year(), 9, 22, 10, 11, 56, 0, time.Local),
+			time:        bsdDate(9, 22, 10, 11, 56, 0, time.Local),
 			hostname:    "cdaix66",
 			application: "sshd",
 			text:        "Accepted publickey for nagios from 1.9.1.1 port 42096 ssh2",
 		},
 		{
 			raw:         []byte("<38>Apr  8 10:03:21 XPS-13-9380 gnome-shell[2332]: Error invoking IBus.set_global_engine_async: Expected function for callback argument callback, got undefined#012setEngine@resource:///org/gnome/shell/misc/ibusManager.js:207:9#012wrapper@resource:///org/gnome/gjs/modules/_legacy.js:82:22"),
-			time:        time.Date(now.Year(), 4, 8, 10, 3, 21, 0, time.Local),
+			time:        bsdDate(4, 8, 10, 3, 21, 0, time.Local),
 			hostname:    "XPS-13-9380",
 			application: "gnome-shell",
 			text:        "Error invoking IBus.set_global_engine_async: Expected function for callback argument callback, got undefined\nsetEngine@resource:///org/gnome/shell/misc/ibusManager.js:207:9\nwrapper@resource:///org/gnome/gjs/modules/_legacy.js:82:22",
@@ -518,7 +535,7 @@ func (s *ParseTestSuite) TestParse() {
 		},
 		{
 			raw:         []byte(`<6> Mar  7 05:45:39 eth systemd[1]: Starting Message of the Day...`),
-			time:        time.Date(time.Now().Year(), 3, 7, 5, 45, 39, 0, time.UTC),
+			time:        bsdDate(3, 7, 5, 45, 39, 0, time.UTC),
 			hostname:    "eth",
 			application: "systemd",
 			text:        "Starting Message of the Day...",
@@ -580,14 +597,17 @@ func (s *ParseTestSuite) TestRFC3164Dates() {
 
 	for _, f := range fixtures {
 		str := string(f.raw)
-		msg, _ := parseSyslogLine(f.raw)
+		msg, _ := parseSyslogLine(f.raw, defaultConfig)
 		assert.NotNil(msg)
 		assert.Equal(int64(2), msg.Severity, str)
 		loc := time.Local
 		if f.isUTC {
 			loc = time.UTC
 		}
-		expectedTS := time.Date(time.Now().Year(), time.October, 1, 22, 14, 15, 0, loc)
+		expectedTS := bsdDate(time.October, 1, 22, 14, 15, 0, loc)
+		if f.isUTC {
+			expectedTS = time.Date(time.Now().Year(), time.October, 1, 22, 14, 15, 0, loc)
+		}
 		assert.Equal(expectedTS, time.Unix(0, msg.Timestamp).In(expectedTS.Location()), str)
 		assert.Equal("mymachine", msg.Hostname, str)
 		assert.Equal("very.large.syslog.message.tag", msg.Application, str)
@@ -595,15 +615,79 @@ func (s *ParseTestSuite) TestRFC3164Dates() {
 	}
 }
 
+func (s *ParseTestSuite) TestFromWallClock() {
+	wall := func(year int, month time.Month, day, hour int) time.Time {
+		return time.Date(year, month, day, hour, 0, 0, 0, time.UTC)
+	}
+
+	cases := []struct {
+		wall time.Time
+		now  time.Time
+		want time.Time
+	}{
+		// sent on Dec 31, received on Jan 1
+		{wall: wall(0, time.December, 31, 23), now: wall(2024, time.January, 1, 0), want: wall(2023, time.December, 31, 23)},
+		// sender clock slightly ahead over new year
+		{wall: wall(0, time.January, 1, 0), now: wall(2023, time.December, 31, 23), want: wall(2024, time.January, 1, 0)},
+		{wall: wall(0, time.June, 1, 12), now: wall(2024, time.June, 1, 13), want: wall(2024, time.June, 1, 12)},
+		// the year is kept if the timestamp has one
+		{wall: wall(2007, time.April, 15, 21), now: wall(2024, time.June, 1, 13), want: wall(2007, time.April, 15, 21)},
+	}
+
+	for _, c := range cases {
+		s.Equal(c.want, fromWallClock(c.wall, time.UTC, c.now), c.wall.String())
+	}
+}
+
+func (s *ParseTestSuite) TestTimeZones() {
+	config := &Config{
+		TimeZone: "America/New_York",
+		TimeZones: []TimeZoneRule{
+			{CIDR: "10.1.0.0/16", TimeZone: "Asia/Tokyo"},
+			{Hostname: "*.eu.example.com", TimeZone: "Europe/Berlin"},
+		},
+	}
+	s.Require().NoError(config.compile())
+
+	loc := func(name string) *time.Location {
+		l, err := time.LoadLocation(name)
+		s.Require().NoError(err)
+		return l
+	}
+
+	cases := []struct {
+		raw        string
+		remoteAddr string
+		want       time.Time
+	}{
+		{raw: "<13>Jun  1 14:40:51 alma korte: message", remoteAddr: "10.2.0.1", want: bsdDate(time.June, 1, 14, 40, 51, 0, loc("America/New_York"))},
+		{raw: "<13>Jun  1 14:40:51 alma korte: message", remoteAddr: "10.1.3.4", want: bsdDate(time.June, 1, 14, 40, 51, 0, loc("Asia/Tokyo"))},
+		{raw: "<13>Jun  1 14:40:51 fw1.EU.example.com korte: message", remoteAddr: "10.2.0.1", want: bsdDate(time.June, 1, 14, 40, 51, 0, loc("Europe/Berlin"))},
+		{raw: "<7>2006-10-29T02:00:00.156 bzorp openvpn[2499]: message", remoteAddr: "10.1.3.4", want: time.Date(2006, 10, 29, 2, 0, 0, 156000000, loc("Asia/Tokyo"))},
+		// timestamps with an offset are left alone
+		{raw: "<7>2006-10-29T02:00:00.156+01:00 bzorp openvpn[2499]: message", remoteAddr: "10.1.3.4", want: time.Date(2006, 10, 29, 1, 0, 0, 156000000, time.UTC)},
+	}
+
+	for _, c := range cases {
+		msg := parseLineWithFallback([]byte(c.raw), c.remoteAddr, config)
+		s.Require().NotNil(msg, c.raw)
+		s.Equal(c.want.UnixNano(), msg.Timestamp, c.raw)
+	}
+
+	s.Error((&Config{TimeZone: "Nowhere/Special"}).compile())
+	s.Error((&Config{TimeZones: []TimeZoneRule{{CIDR: "10.0.0.0/33", TimeZone: "UTC"}}}).compile())
+	s.Error((&Config{TimeZones: []TimeZoneRule{{TimeZone: "UTC"}}}).compile())
+}
+
 func (s *ParseTestSuite) TestRFC3164SequenceID() {
 	assert := assert.New(s.T())
 
 	buff := []byte("<34>214: Oct 11 22:14:15 mymachine very.large.syslog.message.tag: 'su root' failed for lonvick on /dev/pts/8")
 
-	msg, _ := parseSyslogLine(buff)
+	msg, _ := parseSyslogLine(buff, defaultConfig)
 	assert.NotNil(msg)
 	assert.Equal("214", msg.Metadata["SequenceID"])
-	assert.Equal(time.Date(time.Now().Year(), time.October, 11, 22, 14, 15, 0, time.Local), time.Unix(0, msg.Timestamp))
+	assert.Equal(bsdDate(time.October, 11, 22, 14, 15, 0, time.Local), time.Unix(0, msg.Timestamp))
 	assert.Equal("'su root' failed for lonvick on /dev/pts/8", msg.Text)
 }
 
@@ -612,13 +696,75 @@ func (s *ParseTestSuite) TestRFC3164NoTimeOrHost() {
 
 	buff := []byte("<34>214: myprogram[332] 'su root' failed for lonvick on /dev/pts/8")
 
-	msg, _ := parseSyslogLine(buff)
+	msg, _ := parseSyslogLine(buff, defaultConfig)
 	assert.NotNil(msg)
 	assert.Equal("214", msg.Metadata["SequenceID"])
 	assert.Equal(time.Now().UTC().Day(), time.Unix(0, msg.Timestamp).UTC().Day())
 	assert.Equal("'su root' failed for lonvick on /dev/pts/8", msg.Text)
 }
 
+func (s *ParseTestSuite) TestProcID() {
+	cases := []struct {
+		raw    string
+		procID string
+		pid    int64
+	}{
+		{raw: "<15>Jan  1 01:00:00 bzorp openvpn[2499]: PTHREAD support initialized", procID: "2499", pid: 2499},
+		{raw: "<15> openvpn[2499]: PTHREAD support initialized", procID: "2499", pid: 2499},
+		{raw: "<13>Jan  1 14:40:51 alma korte: message"},
+		{raw: "<13>Jan  1 14:40:51 alma korte[main]: message", procID: "main"},
+		{raw: "<134>1 2009-10-16T11:51:56+02:00 host MSExchange_ADAccess 20208 - - hello", procID: "20208", pid: 20208},
+		{raw: "<134>1 2009-10-16T11:51:56+02:00 host app worker-1 - - hello", procID: "worker-1"},
+		{raw: "<134>1 2009-10-16T11:51:56+02:00 host app[42] - - - hello", procID: "42", pid: 42},
+		{raw: "<134>1 2009-10-16T11:51:56+02:00 host app - - - hello"},
+		{raw: "<134>1 2009-10-16T11:51:56+02:00 host app 9223372036854775807 - - hello", procID: "9223372036854775807", pid: 9223372036854775807},
+		{raw: "<134>1 2009-10-16T11:51:56+02:00 host app 9223372036854775808 - - hello", procID: "9223372036854775808"},
+		{raw: "<15>Jan  1 01:00:00 bzorp openvpn[18446744073709551615]: hello", procID: "18446744073709551615"},
+	}
+
+	for _, c := range cases {
+		msg := ParseLineWithFallback([]byte(c.raw), "127.0.0.1")
+		s.Require().NotNil(msg, c.raw)
+		s.Equal(c.procID, msg.ProcID, c.raw)
+		s.Equal(c.pid, msg.PID, c.raw)
+	}
+}
+
+func (s *ParseTestSuite) TestKeepRaw() {
+	config := &Config{KeepRaw: true}
+	s.Require().NoError(config.compile())
+
+	raws := []string{
+		"Use the BFG!\n",
+		"<15> redis: \xef\xbb\xbfutf8isbom",
+		"<34>214: myprogram[332]: #033[32mdebug#033[0m done",
+		"<14>Jan  1 14:40:51 host app[24]: truncated\x00garbage",
+		`{"msg": "hi", "level": "info"}`,
+	}
+	for _, raw := range raws {
+		msg := parseLineWithFallback([]byte(raw), "127.0.0.1", config)
+		s.Require().NotNil(msg, raw)
+		s.Equal(raw, msg.Raw)
+	}
+
+	config = &Config{KeepRaw: true, MaxRawSize: 7}
+	s.Require().NoError(config.compile())
+
+	msg := parseLineWithFallback([]byte("Use the BFG!"), "127.0.0.1", config)
+	s.Require().NotNil(msg)
+	s.Equal("Use the", msg.Raw)
+	s.Equal("Use the BFG!", msg.Text)
+
+	// cut in front of a character that doesn't fit
+	msg = parseLineWithFallback([]byte("Use the ßFG!"), "127.0.0.1", mustCompile(&Config{KeepRaw: true, MaxRawSize: 9}))
+	s.Require().NotNil(msg)
+	s.Equal("Use the ", msg.Raw)
+
+	msg = ParseLineWithFallback([]byte("Use the BFG!"), "127.0.0.1")
+	s.Require().NotNil(msg)
+	s.Empty(msg.Raw)
+}
+
 func (s *ParseTestSuite) TestSynthetic() {
 	msg := ParseLineWithFallback([]byte("foobar2000"), "127.0.0.1")
 	s.Equal("foobar2000", msg.Text)
@@ -631,7 +777,7 @@ func (s *ParseTestSuite) TestSynthetic() {
 }
 
 func (s *ParseTestSuite) TestSyntheticDirect() {
-	msg, _ := syntheticLog("myhost", []byte("This is a message"))
+	msg, _ := syntheticLog("myhost", []byte("This is a message"), defaultConfig)
 	s.Require().NotNil(msg)
 	s.Equal("This is a message", msg.Text)
 	s.Equal("myhost", msg.Hostname)
@@ -645,7 +791,7 @@ func (s *ParseTestSuite) TestFuzzCrashers() {
 	}
 
 	for _, data := range payloads {
-		_, err := parseSyslogLine(data)
+		_, err := parseSyslogLine(data, defaultConfig)
 		s.Error(err)
 	}
 }
@@ -655,7 +801,7 @@ func (s *ParseTestSuite) TestUnescape() {
 
 	buff := []byte("<34>214: myprogram[332]: #033[32mdebug#033[0m #033[37;2mdatastores#033[0m@#033[94mdatastores.statsd#033[0m accumulator.go:149 Encountered err #033")
 
-	msg, _ := parseSyslogLine(buff)
+	msg, _ := parseSyslogLine(buff, defaultConfig)
 	assert.NotNil(msg)
 	assert.Equal("\x1b[32mdebug\x1b[0m \x1b[37;2mdatastores\x1b[0m@\x1b[94mdatastores.statsd\x1b[0m accumulator.go:149 Encountered err #033", msg.Text)
 }
@@ -691,10 +837,115 @@ func (s *ParseTestSuite) TestExtractSeverity() {
 	}
 }
 
+func TestParseNestedJSON(t *testing.T) {
+	config := mustCompile(&Config{NestedJSON: true, MaxJSONDepth: 2, MaxJSONFields: 11})
+
+	msg := parseLineWithFallback([]byte(`{"msg": "hello", "a.h[a]": {"ta.ke": ["on", "m.e"], "float": 4.3, "deep": {"er": {"est": 1}}}, "n": 1}`), "10.1.1.1", config)
+	require.NotNil(t, msg)
+	assert.Equal(t, "hello", msg.Text)
+	assert.Equal(t, map[string]any{
+		"a.h[a]": map[string]any{
+			"ta.ke": []any{"on", "m.e"},
+			"float": 4.3,
+			// too deep
+			"deep": map[string]any{"er": `{"est": 1}`},
+		},
+		"n": int64(1),
+	}, msg.Metadata)
+
+	// too many fields
+	msg = parseLineWithFallback([]byte(`{"small": {"a": 1}, "big": [1, 2, 3, 4, 5, 6, 7, 8, 9], "last": {"b": 2}}`), "10.1.1.1", config)
+	require.NotNil(t, msg)
+	assert.Equal(t, map[string]any{
+		"small": map[string]any{"a": int64(1)},
+		"big":   "[1, 2, 3, 4, 5, 6, 7, 8, 9]",
+		"last":  map[string]any{"b": int64(2)},
+	}, msg.Metadata)
+
+	// top-level fields beyond the limit are kept together
+	flat := mustCompile(&Config{NestedJSON: true, MaxJSONFields: 2})
+	msg = parseLineWithFallback([]byte(`{"a": 1, "b": "two", "c": [3], "d": "f\"our", "e": {"f": 5}}`), "10.1.1.1", flat)
+	require.NotNil(t, msg)
+	assert.Equal(t, map[string]any{
+		"a":              int64(1),
+		"b":              "two",
+		"json.remainder": `{"c":[3],"d":"f\"our","e":{"f": 5}}`,
+	}, msg.Metadata)
+
+	// in the message text too
+	msg = parseLineWithFallback([]byte(`<14>Jan 18 11:07:53 host app: {"user": {"id": 42, "roles": ["admin"]}}`), "10.1.1.1", config)
+	require.NotNil(t, msg)
+	assert.Equal(t, map[string]any{"user": map[string]any{"id": int64(42), "roles": []any{"admin"}}}, msg.Metadata)
+
+	_, err := NewWithConfig(func(*Log) {}, &Config{NestedJSON: true, MaxJSONDepth: -1})
+	assert.Error(t, err)
+}
+
+func TestParseJSONTypes(t *testing.T) {
+	const raw = `{"msg": "typed", "ok": true, "gone": null, "id": 18446744073709551615, "huge": 99999999999999999999, "ratio": 1.5e-3, "list": [null, false]}`
+
+	msg := parseLineWithFallback([]byte(raw), "10.1.1.1", defaultConfig)
+	require.NotNil(t, msg)
+	assert.Equal(t, map[string]any{
+		"ok":      true,
+		"id":      uint64(18446744073709551615),
+		"huge":    "99999999999999999999",
+		"ratio":   0.0015,
+		"list[1]": false,
+	}, msg.Metadata)
+
+	msg = parseLineWithFallback([]byte(raw), "10.1.1.1", mustCompile(&Config{KeepNulls: true, NestedJSON: true}))
+	require.NotNil(t, msg)
+	assert.Equal(t, map[string]any{
+		"ok":    true,
+		"gone":  nil,
+		"id":    uint64(18446744073709551615),
+		"huge":  "99999999999999999999",
+		"ratio": 0.0015,
+		"list":  []any{nil, false},
+	}, msg.Metadata)
+}
+
+func TestParseJSONAfterPrefix(t *testing.T) {
+	config := mustCompile(&Config{JSONAfterPrefix: true})
+
+	msg := parseLineWithFallback([]byte(`<14>Jan 18 11:07:53 host app: myapp: {"level":"warn","msg":"disk full","free":0}`), "10.1.1.1", config)
+	require.NotNil(t, msg)
+	assert.Equal(t, "disk full", msg.Text)
+	assert.EqualValues(t, Warning, msg.Severity)
+	assert.Equal(t, map[string]any{"json.prefix": "myapp:", "free": int64(0)}, msg.Metadata)
+
+	// without a message, the prefix is the text
+	msg = parseLineWithFallback([]byte(`2024-01-01 INFO {"user": {"id": 1}, "note": "a } in {a string"}`), "10.1.1.1", config)
+	require.NotNil(t, msg)
+	assert.Equal(t, "2024-01-01 INFO", msg.Text)
+	assert.Equal(t, map[string]any{"user.id": int64(1), "note": "a } in {a string"}, msg.Metadata)
+
+	// objects within the prefix are skipped
+	msg = parseLineWithFallback([]byte(`<14>Jan 18 11:07:53 host app: got {x} then {"user": 2}`), "10.1.1.1", config)
+	require.NotNil(t, msg)
+	assert.Equal(t, "got {x} then", msg.Text)
+	assert.Equal(t, map[string]any{"user": int64(2)}, msg.Metadata)
+
+	// invalid objects and the default config leave the text alone
+	for _, raw := range []string{
+		`<14>Jan 18 11:07:53 host app: myapp: {not json}`,
+		`<14>Jan 18 11:07:53 host app: myapp: {"user": 1}} trailing`,
+	} {
+		msg = parseLineWithFallback([]byte(raw), "10.1.1.1", config)
+		require.NotNil(t, msg)
+		assert.Empty(t, msg.Metadata, raw)
+	}
+	msg = parseLineWithFallback([]byte(`<14>Jan 18 11:07:53 host app: myapp: {"user": 1}`), "10.1.1.1", defaultConfig)
+	require.NotNil(t, msg)
+	assert.Equal(t, `myapp: {"user": 1}`, msg.Text)
+	assert.Empty(t, msg.Metadata)
+}
+
 func Benchmark5424(b *testing.B) {
 	raw := []byte("<134>1 2009-10-16T11:51:56+02:00 ip-34-23-211-23 symbolicator 2008 SOMEMSG - hello")
 	for b.Loop() {
-		msg, _ := parseSyslogLine(raw)
+		msg, _ := parseSyslogLine(raw, defaultConfig)
 		if msg == nil {
 			panic(errors.New("Unable to parse message"))
 		}
@@ -704,7 +955,7 @@ func Benchmark5424(b *testing.B) {
 func BenchmarkParserDifferentMetadataTypes(b *testing.B) {
 	raw := []byte(`<14> src time="2018-06-02T17:16:14.392415523+01:00" bool=false level=info float=5.6 number=3 msg="[graphdriver] using prior storage driver: aufs"`)
 	for b.Loop() {
-		msg, _ := parseSyslogLine(raw)
+		msg, _ := parseSyslogLine(raw, defaultConfig)
 		if msg == nil {
 			panic(errors.New("Unable to parse message"))
 		}
@@ -712,7 +963,10 @@ func BenchmarkParserDifferentMetadataTypes(b *testing.B) {
 }
 
 func BenchmarkParser(b *testing.B) {
-	p := New(func(_ *Log) {})
+	p, err := NewWithConfig(func(_ *Log) {}, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
 
 	const msg = "<1> 2009-10-16T11:51:56+02:00 ip-34-23-211-23 symbolicator ERROR 2008 SOMEMSG - hello"
 	rawMsg := []byte(msg)
@@ -729,7 +983,7 @@ func BenchmarkParser(b *testing.B) {
 func BenchmarkDateParse(b *testing.B) {
 	raw := []byte("<13>Jan  1 14:40:51 host app[24]: this is the message")
 	for b.Loop() {
-		msg, _ := parseSyslogLine(raw)
+		msg, _ := parseSyslogLine(raw, defaultConfig)
 		if msg == nil {
 			panic(errors.New("Unable to parse message"))
 		}
@@ -739,7 +993,7 @@ func BenchmarkDateParse(b *testing.B) {
 func BenchmarkNoDateParse(b *testing.B) {
 	raw := []byte("<13>host app[24]: this is the message")
 	for b.Loop() {
-		msg, _ := parseSyslogLine(raw)
+		msg, _ := parseSyslogLine(raw, defaultConfig)
 		if msg == nil {
 			panic(errors.New("Unable to parse message"))
 		}
@@ -840,3 +1094,20 @@ func Test_detectMaybeJSON(t *testing.T) {
 		})
 	}
 }
+
+func TestNewWithConfig(t *testing.T) {
+	// the config may be shared between parsers, so it's left as it is
+	config := &Config{TimeZone: "UTC", TimeZones: []TimeZoneRule{{CIDR: "10.0.0.0/8", TimeZone: "Europe/Berlin"}}}
+	want := *config
+	for range 2 {
+		_, err := NewWithConfig(func(*Log) {}, config)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, want, *config)
+
+	var logs []*Log
+	p := New(func(msg *Log) { logs = append(logs, msg) })
+	p.WriteLine([]byte("<14>Jan 18 11:07:53 host app: hello"), "10.1.1.1")
+	require.Len(t, logs, 1)
+	assert.Equal(t, "hello", logs[0].Text)
+}
@@ -1,5 +1,7 @@
 package parser
 
+import "time"
+
 // Parser ...
 type Parser interface {
 	WriteLine(line []byte, remoteIP string)
@@ -11,14 +13,60 @@ type Parser interface {
 type ProcessLogFunc func(msg *Log)
 
 type parser struct {
-	emitLog ProcessLogFunc
+	emitLog   ProcessLogFunc
+	config    *Config
+	partials  *partialBuffer
+	multiline *multilineBuffer
+	mails     *mailBuffer
 }
 
-// New ...
+// New creates a Parser with the default config that hands every parsed
+// message to cb.
 func New(cb ProcessLogFunc) Parser {
-	return &parser{
+	p, err := NewWithConfig(cb, nil)
+	if err != nil {
+		// the default config is always valid
+		panic(err)
+	}
+	return p
+}
+
+// NewWithConfig creates a Parser that hands every parsed message to cb. A nil
+// config applies the defaults. The config isn't modified, so it may be shared
+// between parsers.
+func NewWithConfig(cb ProcessLogFunc, config *Config) (Parser, error) {
+	var c Config
+	if config != nil {
+		c = *config
+	}
+	config = &c
+	if err := config.compile(); err != nil {
+		return nil, err
+	}
+
+	p := &parser{
 		emitLog: cb,
+		config:  config,
+		partials: newPartialBuffer(
+			config.PartialTimeout.orDefault(defaultPartialTimeout),
+			orDefault(config.MaxPartialSize, defaultMaxPartialSize),
+			orDefault(config.MaxPendingPartials, defaultMaxPending),
+		),
+	}
+	if len(config.multilineRules) > 0 {
+		p.multiline = newMultilineBuffer(
+			config.multilineRules,
+			orDefault(config.MaxPendingMultiline, defaultMaxPending),
+			config.MaxRawSize,
+		)
 	}
+	if config.MailCorrelation {
+		p.mails = newMailBuffer(
+			config.MailTimeout.orDefault(defaultMailTimeout),
+			orDefault(config.MaxPendingMails, defaultMaxPending),
+		)
+	}
+	return p, nil
 }
 
 func (p *parser) WriteLine(line []byte, remoteIP string) {
@@ -26,19 +74,75 @@ func (p *parser) WriteLine(line []byte, remoteIP string) {
 	// we'll be able to:
 	// a) Be able to take into account the specific log parsing settings of the instance and,
 	// b) Intiialize & involve integrations for parsing specific log types
-	if msg := ParseLineWithFallback(line, remoteIP); msg != nil {
-		if msg.Text == "" {
-			return
-		}
+	msg := parseLine(line, remoteIP, p.config)
+	if msg == nil {
+		return
+	}
+
+	now := time.Now()
+	if !msg.reassemble {
+		p.join(msg, now)
+		return
+	}
+
+	for _, msg := range p.partials.add(msg, p.config, now) {
+		p.join(msg, now)
+	}
+}
 
-		p.emitLog(msg)
+// join emits msg, or adds it to the multiline message it belongs to.
+func (p *parser) join(msg *Log, now time.Time) {
+	if p.multiline == nil {
+		p.emit(msg)
+		return
+	}
+
+	for _, msg := range p.multiline.add(msg, now) {
+		p.emit(msg)
+	}
+}
+
+func (p *parser) emit(msg *Log) {
+	finishLog(msg, p.config)
+	if msg.Text == "" {
+		return
+	}
+
+	p.emitLog(msg)
+
+	if p.mails != nil {
+		for _, summary := range p.mails.add(msg, time.Now()) {
+			p.emitLog(summary)
+		}
 	}
 }
 
+// Flush emits the partial and multiline messages that weren't continued in
+// time, and the summaries of mail that wasn't heard of in time.
 func (p *parser) Flush() error {
+	p.expire(false)
 	return nil
 }
 
+// Stop emits all partial and multiline messages and pending mail summaries.
 func (p *parser) Stop() error {
+	p.expire(true)
 	return nil
 }
+
+func (p *parser) expire(all bool) {
+	now := time.Now()
+	for _, msg := range p.partials.expire(p.config, now, all) {
+		p.join(msg, now)
+	}
+	if p.multiline != nil {
+		for _, msg := range p.multiline.expire(now, all) {
+			p.emit(msg)
+		}
+	}
+	if p.mails != nil {
+		for _, summary := range p.mails.expire(now, all) {
+			p.emitLog(summary)
+		}
+	}
+}
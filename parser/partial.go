@@ -0,0 +1,111 @@
+package parser
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultPartialTimeout = 5 * time.Second
+	defaultMaxPartialSize = 1 << 20
+)
+
+// partialKey identifies the stream a partial line belongs to.
+type partialKey struct {
+	remoteAddr  string
+	hostname    string
+	application string
+	containerID any
+	stream      any
+}
+
+func partialKeyOf(msg *Log) partialKey {
+	return partialKey{
+		remoteAddr:  msg.RemoteAddr,
+		hostname:    msg.Hostname,
+		application: msg.Application,
+		containerID: msg.Metadata[containerIDKey],
+		stream:      msg.Metadata[streamKey],
+	}
+}
+
+type partialLog struct {
+	msg     *Log
+	text    strings.Builder
+	updated time.Time
+}
+
+// complete returns the reassembled message.
+func (p *partialLog) complete(config *Config) *Log {
+	p.msg.Text = p.text.String()
+	p.msg.partial = false
+	parseMetadata(p.msg, []byte(p.msg.Text), config)
+	return p.msg
+}
+
+// partialBuffer reassembles messages that were split into partial lines.
+// Messages are given up on, and passed on as far as they got, once they
+// exceed maxSize, weren't continued within timeout or are the oldest of more
+// than maxPending.
+type partialBuffer struct {
+	mu      sync.Mutex
+	pending *pendingMap[partialKey, *partialLog]
+	timeout time.Duration
+	maxSize int
+}
+
+func newPartialBuffer(timeout time.Duration, maxSize, maxPending int) *partialBuffer {
+	return &partialBuffer{
+		pending: newPendingMap[partialKey, *partialLog](maxPending),
+		timeout: timeout,
+		maxSize: maxSize,
+	}
+}
+
+// add appends msg to the message it continues. It returns the messages that
+// are complete, in order, which are none while more of msg is expected.
+func (b *partialBuffer) add(msg *Log, config *Config, now time.Time) []*Log {
+	key := partialKeyOf(msg)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var complete []*Log
+	p, ok := b.pending.get(key)
+	if !ok {
+		if !msg.partial {
+			return []*Log{msg}
+		}
+		p = &partialLog{msg: msg}
+		if evicted, ok := b.pending.add(key, p); ok {
+			complete = append(complete, evicted.complete(config))
+		}
+	} else {
+		p.msg.Raw = joinRaw(p.msg.Raw, msg.Raw, config.MaxRawSize)
+	}
+
+	p.text.WriteString(msg.Text)
+	p.updated = now
+	if msg.partial && p.text.Len() < b.maxSize {
+		return complete
+	}
+
+	b.pending.delete(key)
+	return append(complete, p.complete(config))
+}
+
+// expire removes the messages that weren't continued within the timeout, or
+// all of them, and returns them as far as they got.
+func (b *partialBuffer) expire(config *Config, now time.Time, all bool) []*Log {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var expired []*Log
+	for _, p := range b.pending.removeIf(func(p *partialLog) bool {
+		return all || now.Sub(p.updated) >= b.timeout
+	}) {
+		expired = append(expired, p.complete(config))
+	}
+	return expired
+}
@@ -0,0 +1,77 @@
+package parser
+
+import "container/list"
+
+// defaultMaxPending is the number of messages a buffer holds while waiting
+// for more of them.
+const defaultMaxPending = 10000
+
+// pendingMap holds the messages a buffer is waiting for more of. It keeps
+// them in the order they were last updated in, so the oldest one can be given
+// up on once there are too many, as every sender can start any number of
+// them.
+type pendingMap[K comparable, V any] struct {
+	entries map[K]*list.Element
+	order   list.List
+	max     int
+}
+
+type pendingEntry[K comparable, V any] struct {
+	key   K
+	value V
+}
+
+func newPendingMap[K comparable, V any](max int) *pendingMap[K, V] {
+	return &pendingMap[K, V]{
+		entries: map[K]*list.Element{},
+		max:     max,
+	}
+}
+
+// get returns the value of key and marks it as the most recently updated.
+func (m *pendingMap[K, V]) get(key K) (V, bool) {
+	e, ok := m.entries[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	m.order.MoveToBack(e)
+	return e.Value.(*pendingEntry[K, V]).value, true
+}
+
+// add adds the value of a new key. If the map is full, it removes and returns
+// the value updated the longest time ago.
+func (m *pendingMap[K, V]) add(key K, value V) (evicted V, ok bool) {
+	if len(m.entries) >= m.max {
+		if e := m.order.Front(); e != nil {
+			entry := m.order.Remove(e).(*pendingEntry[K, V])
+			delete(m.entries, entry.key)
+			evicted, ok = entry.value, true
+		}
+	}
+	m.entries[key] = m.order.PushBack(&pendingEntry[K, V]{key: key, value: value})
+	return evicted, ok
+}
+
+func (m *pendingMap[K, V]) delete(key K) {
+	if e, ok := m.entries[key]; ok {
+		m.order.Remove(e)
+		delete(m.entries, key)
+	}
+}
+
+// removeIf removes the values done reports true for, oldest first, and
+// returns them in that order.
+func (m *pendingMap[K, V]) removeIf(done func(V) bool) []V {
+	var removed []V
+	for e := m.order.Front(); e != nil; {
+		next := e.Next()
+		if entry := e.Value.(*pendingEntry[K, V]); done(entry.value) {
+			m.order.Remove(e)
+			delete(m.entries, entry.key)
+			removed = append(removed, entry.value)
+		}
+		e = next
+	}
+	return removed
+}
@@ -0,0 +1,141 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sync"
+)
+
+// AppParser parses the format of an application, or any other format
+// messages can be recognised by, into the fields of a Log.
+//
+// A parser applies to the messages whose application is one of
+// Applications, matches ApplicationRegex, or that Detect reports, and to all
+// messages if none of them are set. Parsers run in the order of their
+// priority, the first to report success wins.
+type AppParser struct {
+	// Name identifies the parser in Config.Parsers.
+	Name string
+	// Applications are the names of the applications the parser applies to.
+	Applications []string
+	// ApplicationRegex matches the applications the parser applies to.
+	ApplicationRegex *regexp.Regexp
+	// Detect reports whether the parser applies to a message.
+	Detect func(msg *Log) bool
+	// Priority orders the parsers, higher priorities run first. Parsers of
+	// the same priority run in the order they were registered. The built-in
+	// parsers have priority zero.
+	Priority int
+	// Disabled parsers only run when enabled in Config.Parsers.
+	Disabled bool
+	// Parse parses msg in place and reports whether it recognised it. It
+	// must not change msg if it didn't.
+	Parse func(msg *Log, config *Config) bool
+	// Stage is the point of parsing the parser runs at. Defaults to
+	// StageText.
+	Stage Stage
+}
+
+// Stage is the point of parsing an AppParser runs at.
+type Stage int
+
+const (
+	// StageText parsers run on the complete message text, before any JSON in
+	// it is merged.
+	StageText Stage = iota
+	// StageHeader parsers run on syslog lines that aren't RFC 5424, before
+	// they are parsed as RFC 3164. The text of the message they get is the
+	// whole line, and its other fields are empty, so they only apply to all
+	// messages or to those Detect reports. Parsers that recognise the line
+	// set all fields of the message.
+	StageHeader
+	// StageLine parsers run on every line, before partial lines are
+	// reassembled and multiline messages joined.
+	StageLine
+	// StageJSON parsers run on the fields of a JSON message, once it is
+	// merged.
+	StageJSON
+)
+
+func (p *AppParser) appliesTo(msg *Log) bool {
+	if len(p.Applications) == 0 && p.ApplicationRegex == nil && p.Detect == nil {
+		return true
+	}
+	return slices.Contains(p.Applications, msg.Application) ||
+		p.ApplicationRegex != nil && p.ApplicationRegex.MatchString(msg.Application) ||
+		p.Detect != nil && p.Detect(msg)
+}
+
+var registry struct {
+	mu      sync.RWMutex
+	parsers []*AppParser
+}
+
+// Register adds a parser for all Parsers, including those created before.
+// It is meant to be called from init functions.
+func Register(p AppParser) error {
+	if p.Name == "" {
+		return errors.New("parser has no name")
+	}
+	if p.Parse == nil {
+		return fmt.Errorf("parser %q has no parse function", p.Name)
+	}
+
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	if slices.ContainsFunc(registry.parsers, func(other *AppParser) bool { return other.Name == p.Name }) {
+		return fmt.Errorf("parser %q is already registered", p.Name)
+	}
+
+	// after all parsers of the same or higher priority
+	i := slices.IndexFunc(registry.parsers, func(other *AppParser) bool { return other.Priority < p.Priority })
+	if i < 0 {
+		i = len(registry.parsers)
+	}
+	// copied, as the parsers are run without holding the lock
+	registry.parsers = slices.Insert(slices.Clone(registry.parsers), i, &p)
+	return nil
+}
+
+func mustRegister(p AppParser) {
+	if err := Register(p); err != nil {
+		panic(err)
+	}
+}
+
+// unregister removes the parser of the given name, if there is one.
+func unregister(name string) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	registry.parsers = slices.DeleteFunc(slices.Clone(registry.parsers), func(p *AppParser) bool { return p.Name == name })
+}
+
+// isRegistered reports whether there is a parser of the given name.
+func isRegistered(name string) bool {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	return slices.ContainsFunc(registry.parsers, func(p *AppParser) bool { return p.Name == name })
+}
+
+// runAppParsers runs the enabled parsers of the stage that apply to msg until
+// one of them recognises it, and reports whether one did.
+func runAppParsers(msg *Log, config *Config, stage Stage) bool {
+	registry.mu.RLock()
+	parsers := registry.parsers
+	registry.mu.RUnlock()
+
+	for _, p := range parsers {
+		if p.Stage != stage || !config.parserEnabled(p) || !p.appliesTo(msg) {
+			continue
+		}
+		if p.Parse(msg, config) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,138 @@
+package parser
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegister(t *testing.T) {
+	parseInHouse := func(msg *Log, _ *Config) bool {
+		key, value, ok := strings.Cut(msg.Text, "|")
+		if !ok {
+			return false
+		}
+		msg.Metadata["inhouse."+key] = value
+		return true
+	}
+	t.Cleanup(func() {
+		for _, name := range []string{"test-inhouse", "test-cef", "test-optin", "test-header", "test-line"} {
+			unregister(name)
+		}
+	})
+
+	require.NoError(t, Register(AppParser{
+		Name:             "test-inhouse",
+		ApplicationRegex: regexp.MustCompile(`^inhouse-`),
+		Parse:            parseInHouse,
+	}))
+	// runs before the built-in CEF parser
+	require.NoError(t, Register(AppParser{
+		Name:         "test-cef",
+		Applications: []string{"test-cef"},
+		Priority:     1,
+		Parse: func(msg *Log, _ *Config) bool {
+			msg.Metadata["test"] = true
+			return true
+		},
+	}))
+	require.NoError(t, Register(AppParser{
+		Name:     "test-optin",
+		Detect:   func(msg *Log) bool { return msg.Hostname == "optin" },
+		Disabled: true,
+		Parse: func(msg *Log, _ *Config) bool {
+			msg.Application = "opted-in"
+			return true
+		},
+	}))
+
+	// and at the other stages of parsing
+	require.NoError(t, Register(AppParser{
+		Name:   "test-header",
+		Stage:  StageHeader,
+		Detect: func(msg *Log) bool { return strings.HasPrefix(msg.Text, "<14>INHOUSE|") },
+		Parse: func(msg *Log, _ *Config) bool {
+			msg.Hostname, msg.Text, _ = strings.Cut(strings.TrimPrefix(msg.Text, "<14>INHOUSE|"), "|")
+			msg.Application = "inhouse-header"
+			msg.Severity = Info
+			return true
+		},
+	}))
+	require.NoError(t, Register(AppParser{
+		Name:         "test-line",
+		Stage:        StageLine,
+		Applications: []string{"inhouse-header"},
+		Parse: func(msg *Log, _ *Config) bool {
+			msg.Metadata["line"] = true
+			return true
+		},
+	}))
+
+	msg := ParseLineWithFallback([]byte("<14>INHOUSE|host|hello"), "10.1.1.1")
+	require.NotNil(t, msg)
+	assert.Equal(t, "host", msg.Hostname)
+	assert.Equal(t, "inhouse-header", msg.Application)
+	assert.Equal(t, "hello", msg.Text)
+	assert.Equal(t, map[string]any{"line": true}, msg.Metadata)
+
+	msg = ParseLineWithFallback([]byte("<14>Jan 18 11:07:53 host inhouse-billing: user|alice"), "10.1.1.1")
+	require.NotNil(t, msg)
+	assert.Equal(t, map[string]any{"inhouse.user": "alice"}, msg.Metadata)
+
+	msg = ParseLineWithFallback([]byte("<14>Jan 18 11:07:53 host test-cef: CEF:0|Corp|IDS|3|7|Port scan|1|src=10.0.0.1"), "10.1.1.1")
+	require.NotNil(t, msg)
+	assert.Equal(t, map[string]any{"test": true}, msg.Metadata)
+
+	// disabled parsers only run when enabled
+	msg = ParseLineWithFallback([]byte("<14>Jan 18 11:07:53 optin app: hello"), "10.1.1.1")
+	require.NotNil(t, msg)
+	assert.Equal(t, "app", msg.Application)
+
+	config := mustCompile(&Config{Parsers: map[string]bool{"test-optin": true, "cef": false}})
+	msg = parseLineWithFallback([]byte("<14>Jan 18 11:07:53 optin app: hello"), "10.1.1.1", config)
+	require.NotNil(t, msg)
+	assert.Equal(t, "opted-in", msg.Application)
+
+	// and built-in ones can be disabled
+	msg = parseLineWithFallback([]byte("<14>Jan 18 11:07:53 host app: CEF:0|Corp|IDS|3|7|Port scan|1|src=10.0.0.1"), "10.1.1.1", config)
+	require.NotNil(t, msg)
+	assert.NotContains(t, msg.Metadata, "cef.deviceVendor")
+
+	assert.Error(t, Register(AppParser{Name: "test-inhouse", Parse: parseInHouse}))
+	assert.Error(t, Register(AppParser{Name: "test-noparse"}))
+	assert.Error(t, Register(AppParser{Parse: parseInHouse}))
+
+	_, err := NewWithConfig(func(*Log) {}, &Config{Parsers: map[string]bool{"unknown": true}})
+	assert.Error(t, err)
+}
+
+func TestDisableBuiltinParsers(t *testing.T) {
+	const (
+		cisco  = "<189>45: Jan 18 2024 11:07:53.123 UTC: %SYS-5-CONFIG_I: Configured from console by admin on vty0 (10.0.0.1)"
+		docker = "<30>Jan 18 11:07:53 node1 docker/0123456789ab[812]: listening on :8080"
+		cri    = "<30>Jan 18 11:07:53 node1 kubelet: 2024-01-18T11:07:53.123456789Z stdout P first half"
+	)
+
+	msg := ParseLineWithFallback([]byte(cisco), "10.1.1.1")
+	require.NotNil(t, msg)
+	assert.Contains(t, msg.Metadata, "cisco.mnemonic")
+
+	config := mustCompile(&Config{Parsers: map[string]bool{"cisco": false}})
+	msg = parseLineWithFallback([]byte(cisco), "10.1.1.1", config)
+	require.NotNil(t, msg)
+	assert.NotContains(t, msg.Metadata, "cisco.mnemonic")
+
+	config = mustCompile(&Config{Parsers: map[string]bool{"container": false}})
+	msg = parseLineWithFallback([]byte(docker), "10.1.1.1", config)
+	require.NotNil(t, msg)
+	assert.Equal(t, "docker/0123456789ab", msg.Application)
+	assert.NotContains(t, msg.Metadata, "container.id")
+
+	msg = parseLine([]byte(cri), "10.1.1.1", config)
+	require.NotNil(t, msg)
+	assert.False(t, msg.reassemble)
+	assert.Equal(t, "2024-01-18T11:07:53.123456789Z stdout P first half", msg.Text)
+}
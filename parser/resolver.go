@@ -0,0 +1,125 @@
+package parser
+
+import (
+	"container/list"
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultResolveCacheSize     = 4096
+	defaultResolveTTL           = time.Hour
+	defaultResolveNegativeTTL   = 5 * time.Minute
+	defaultResolveTimeout       = 2 * time.Second
+	defaultMaxConcurrentResolve = 16
+)
+
+// Resolver looks up the names of an address. *net.Resolver implements it.
+type Resolver interface {
+	LookupAddr(ctx context.Context, addr string) ([]string, error)
+}
+
+type hostnameEntry struct {
+	addr    string
+	name    string
+	expires time.Time
+}
+
+// hostnameCache resolves addresses to hostnames in the background and keeps
+// the results, including failed lookups, in a TTL bound LRU cache.
+type hostnameCache struct {
+	resolver    Resolver
+	size        int
+	ttl         time.Duration
+	negativeTTL time.Duration
+	timeout     time.Duration
+	slots       chan struct{}
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+	pending map[string]struct{}
+}
+
+func newHostnameCache(resolver Resolver, size int, ttl, negativeTTL, timeout time.Duration, maxConcurrent int) *hostnameCache {
+	return &hostnameCache{
+		resolver:    resolver,
+		size:        size,
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		timeout:     timeout,
+		slots:       make(chan struct{}, maxConcurrent),
+		entries:     make(map[string]*list.Element, size),
+		lru:         list.New(),
+		pending:     map[string]struct{}{},
+	}
+}
+
+// lookup returns the cached hostname of addr. On a miss it starts resolving
+// addr in the background, unless too many lookups are in flight already, so
+// the name is only available to later calls. It never blocks on the network.
+func (c *hostnameCache) lookup(addr string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[addr]; ok {
+		entry := el.Value.(*hostnameEntry)
+		if time.Now().Before(entry.expires) {
+			c.lru.MoveToFront(el)
+			return entry.name, entry.name != ""
+		}
+		c.lru.Remove(el)
+		delete(c.entries, addr)
+	}
+
+	if _, ok := c.pending[addr]; ok {
+		return "", false
+	}
+
+	select {
+	case c.slots <- struct{}{}:
+	default:
+		// all slots are busy, a later message will try again
+		return "", false
+	}
+
+	c.pending[addr] = struct{}{}
+	go c.resolve(addr)
+
+	return "", false
+}
+
+func (c *hostnameCache) resolve(addr string) {
+	defer func() { <-c.slots }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	var name string
+	if names, err := c.resolver.LookupAddr(ctx, addr); err == nil && len(names) > 0 {
+		name = strings.TrimSuffix(names[0], ".")
+	}
+
+	ttl := c.ttl
+	if name == "" {
+		ttl = c.negativeTTL
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.pending, addr)
+	c.entries[addr] = c.lru.PushFront(&hostnameEntry{
+		addr:    addr,
+		name:    name,
+		expires: time.Now().Add(ttl),
+	})
+
+	for c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*hostnameEntry).addr)
+	}
+}
@@ -0,0 +1,135 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubResolver struct {
+	mu      sync.Mutex
+	names   map[string]string
+	calls   map[string]int
+	release chan struct{}
+}
+
+func (r *stubResolver) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	if r.release != nil {
+		select {
+		case <-r.release:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.calls[addr]++
+	if name, ok := r.names[addr]; ok {
+		return []string{name}, nil
+	}
+	return nil, errors.New("no such host")
+}
+
+func (r *stubResolver) callCount(addr string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.calls[addr]
+}
+
+func TestHostnameCache(t *testing.T) {
+	resolver := &stubResolver{
+		names: map[string]string{"10.0.0.1": "fw1.example.com.", "10.0.0.2": "fw2.example.com."},
+		calls: map[string]int{},
+	}
+	cache := newHostnameCache(resolver, 2, time.Hour, time.Hour, time.Second, 4)
+
+	// the first lookup never waits for the resolver
+	_, ok := cache.lookup("10.0.0.1")
+	assert.False(t, ok)
+
+	assert.Eventually(t, func() bool {
+		name, ok := cache.lookup("10.0.0.1")
+		return ok && name == "fw1.example.com"
+	}, time.Second, time.Millisecond)
+
+	// failures are cached as well
+	_, _ = cache.lookup("10.0.0.9")
+	assert.Eventually(t, func() bool { return resolver.callCount("10.0.0.9") == 1 }, time.Second, time.Millisecond)
+	for range 10 {
+		_, ok = cache.lookup("10.0.0.9")
+		assert.False(t, ok)
+	}
+	assert.Equal(t, 1, resolver.callCount("10.0.0.9"))
+	assert.Equal(t, 1, resolver.callCount("10.0.0.1"))
+
+	// adding a third address evicts the least recently used one
+	_, _ = cache.lookup("10.0.0.1")
+	_, _ = cache.lookup("10.0.0.2")
+	assert.Eventually(t, func() bool {
+		_, ok := cache.lookup("10.0.0.2")
+		return ok
+	}, time.Second, time.Millisecond)
+	_, _ = cache.lookup("10.0.0.9")
+	assert.Eventually(t, func() bool { return resolver.callCount("10.0.0.9") == 2 }, time.Second, time.Millisecond)
+}
+
+func TestHostnameCacheExpiry(t *testing.T) {
+	resolver := &stubResolver{
+		names: map[string]string{"10.0.0.1": "fw1.example.com."},
+		calls: map[string]int{},
+	}
+	cache := newHostnameCache(resolver, 8, time.Millisecond, time.Millisecond, time.Second, 4)
+
+	_, _ = cache.lookup("10.0.0.1")
+	assert.Eventually(t, func() bool { return resolver.callCount("10.0.0.1") == 1 }, time.Second, time.Millisecond)
+
+	time.Sleep(5 * time.Millisecond)
+	_, _ = cache.lookup("10.0.0.1")
+	assert.Eventually(t, func() bool { return resolver.callCount("10.0.0.1") == 2 }, time.Second, time.Millisecond)
+}
+
+func TestHostnameCacheConcurrency(t *testing.T) {
+	resolver := &stubResolver{
+		names:   map[string]string{},
+		calls:   map[string]int{},
+		release: make(chan struct{}),
+	}
+	cache := newHostnameCache(resolver, 8, time.Hour, time.Hour, time.Second, 1)
+
+	_, _ = cache.lookup("10.0.0.1")
+	// the only slot is busy, so these neither block nor start a lookup
+	_, _ = cache.lookup("10.0.0.1")
+	_, _ = cache.lookup("10.0.0.2")
+
+	close(resolver.release)
+	assert.Eventually(t, func() bool { return resolver.callCount("10.0.0.1") == 1 }, time.Second, time.Millisecond)
+	assert.Equal(t, 0, resolver.callCount("10.0.0.2"))
+}
+
+func TestResolveHostnames(t *testing.T) {
+	config := &Config{
+		ResolveHostnames: true,
+		Resolver: &stubResolver{
+			names: map[string]string{"10.0.0.1": "fw1.example.com."},
+			calls: map[string]int{},
+		},
+	}
+	require.NoError(t, config.compile())
+
+	assert.Eventually(t, func() bool {
+		msg := parseLineWithFallback([]byte("Use the BFG!"), "10.0.0.1", config)
+		return msg != nil && msg.ResolvedHostname == "fw1.example.com"
+	}, time.Second, time.Millisecond)
+
+	msg := parseLineWithFallback([]byte("Use the BFG!"), "10.0.0.1", config)
+	require.NotNil(t, msg)
+	assert.Equal(t, "10.0.0.1", msg.Hostname)
+	assert.Equal(t, "fw1.example.com", msg.ResolvedHostname)
+}
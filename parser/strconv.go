@@ -1,7 +1,10 @@
 package parser
 
 import (
+	"bytes"
+	"math"
 	"strconv"
+	"strings"
 )
 
 const (
@@ -27,7 +30,11 @@ func ParseUInt(str []byte) (uint64, error) {
 	var result uint64
 	for _, char := range str {
 		if char >= ascii0 && char <= ascii9 {
-			result = result*10 + (uint64(char) - ascii0)
+			digit := uint64(char) - ascii0
+			if result > (math.MaxUint64-digit)/10 {
+				return 0, &NumError{Func: "ParseUInt", Num: string(str), Err: strconv.ErrRange}
+			}
+			result = result*10 + digit
 		} else {
 			return 0, &NumError{Func: "ParseUInt", Num: string(str), Err: strconv.ErrSyntax}
 		}
@@ -50,9 +57,17 @@ func ParseInt(str []byte) (int64, error) {
 		case char < ascii0 || char > ascii9:
 			return 0, &NumError{Func: "ParseInt", Num: string(str), Err: strconv.ErrSyntax}
 		case isPositive:
-			result = result*10 + (int64(char) - ascii0)
+			digit := int64(char) - ascii0
+			if result > (math.MaxInt64-digit)/10 {
+				return 0, &NumError{Func: "ParseInt", Num: string(str), Err: strconv.ErrRange}
+			}
+			result = result*10 + digit
 		case !isPositive:
-			result = result*10 - (int64(char) - ascii0)
+			digit := int64(char) - ascii0
+			if result < (math.MinInt64+digit)/10 {
+				return 0, &NumError{Func: "ParseInt", Num: string(str), Err: strconv.ErrRange}
+			}
+			result = result*10 - digit
 		}
 	}
 
@@ -61,8 +76,12 @@ func ParseInt(str []byte) (int64, error) {
 
 // ParseFloat is similar to strconv.ParseFloat, but operates on []byte which can save a string allocation
 // and is therefore faster
-// Note that it currently doesn't support exponents (scientific format of floats)
+// Numbers with an exponent (scientific format of floats) are handed to strconv.ParseFloat
 func ParseFloat(str []byte) (float64, error) {
+	if bytes.ContainsAny(str, "eE") {
+		return parseFloatExponent(str)
+	}
+
 	var lResult float64
 	var rResult float64
 	isPositive := true
@@ -108,3 +127,43 @@ func ParseFloat(str []byte) (float64, error) {
 
 	return lResult, nil
 }
+
+func parseFloatExponent(str []byte) (float64, error) {
+	f, err := strconv.ParseFloat(string(str), 64)
+	if err != nil {
+		return 0.0, &NumError{Func: "ParseFloat", Num: string(str), Err: err.(*strconv.NumError).Err}
+	}
+	return f, nil
+}
+
+// numberValue types a number: integers are int64, or uint64 if they are
+// positive and too large, everything else is float64. Numbers that don't fit
+// any of them are kept as strings rather than losing precision or range. It
+// reports false if str isn't a number.
+func numberValue(str []byte) (any, bool) {
+	n, err := ParseInt(str)
+	if err == nil {
+		return n, true
+	}
+	if err.(*NumError).Err == strconv.ErrRange {
+		if n, err := ParseUInt(str); err == nil {
+			return n, true
+		}
+		return string(str), true
+	}
+
+	// strconv rounds correctly, where ParseFloat may be off by the last bit,
+	// but also accepts hexadecimal, infinities and NaN
+	if bytes.ContainsFunc(str, func(r rune) bool { return !strings.ContainsRune("0123456789+-.eE", r) }) {
+		return nil, false
+	}
+	f, err := strconv.ParseFloat(string(str), 64)
+	switch {
+	case err == nil:
+		return f, true
+	case err.(*strconv.NumError).Err == strconv.ErrRange:
+		return string(str), true
+	default:
+		return nil, false
+	}
+}
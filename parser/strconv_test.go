@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"math"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -54,3 +55,43 @@ func TestParseFloat(t *testing.T) {
 		}
 	}
 }
+
+func TestParseOverflow(t *testing.T) {
+	_, err := ParseInt([]byte("9223372036854775808"))
+	assert.Error(t, err)
+	_, err = ParseInt([]byte("-9223372036854775809"))
+	assert.Error(t, err)
+	_, err = ParseUInt([]byte("18446744073709551616"))
+	assert.Error(t, err)
+
+	n, err := ParseInt([]byte("-9223372036854775808"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(math.MinInt64), n)
+	}
+	f, err := ParseFloat([]byte("-1.5e-3"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, -0.0015, f)
+	}
+}
+
+func TestNumberValue(t *testing.T) {
+	var testData = map[string]any{
+		"42":                   int64(42),
+		"-9223372036854775808": int64(math.MinInt64),
+		"18446744073709551615": uint64(math.MaxUint64),
+		"18446744073709551616": "18446744073709551616",
+		"-9223372036854775809": "-9223372036854775809",
+		"0.1":                  0.1,
+		"1E3":                  float64(1000),
+		"1e400":                "1e400",
+		"0x1p3":                nil,
+		"NaN":                  nil,
+		"12abc":                nil,
+	}
+
+	for key, value := range testData {
+		res, ok := numberValue([]byte(key))
+		assert.Equal(t, value != nil, ok, key)
+		assert.Equal(t, value, res, key)
+	}
+}
@@ -3,6 +3,7 @@ package parser
 import (
 	"bytes"
 	"errors"
+	"math"
 	"regexp"
 	"strconv"
 	"strings"
@@ -36,6 +37,13 @@ var (
 	// these are #011, #012, #015 (TAB, LF, CR)
 	escapedCtrlCharsRegex = regexp.MustCompile(`#01[125]`)
 
+	// payloadMarkers start self-describing payloads that may directly follow
+	// the syslog header, without a hostname or tag in between.
+	payloadMarkers = [][]byte{[]byte(cefPrefix), []byte(leefPrefix), []byte(snarePrefix), []byte(winCollectPrefix)}
+	// payloadDetectors recognise such payloads that don't start with a
+	// fixed marker.
+	payloadDetectors = []func(data []byte) bool{isFortinet, isPANOS, isCRI}
+
 	errParse         = errors.New("parsing error")
 	errCorruptedData = errors.New("corrupted data")
 )
@@ -47,7 +55,7 @@ const (
 	dateFormatISO
 )
 
-func parseSyslog(data []byte) (*Log, error) {
+func parseSyslog(data []byte, config *Config) (*Log, error) {
 	msg := &Log{
 		Severity: Unknown,
 		Metadata: map[string]any{},
@@ -63,8 +71,15 @@ func parseSyslog(data []byte) (*Log, error) {
 	}
 
 	var parseErr error
-	if parseErr = parseRFC5424(msg, data, length); parseErr == errParse {
-		parseErr = parseRFC3164(msg, data, length)
+	if parseErr = parseRFC5424(msg, data, length, config); parseErr == errParse {
+		// formats that are neither, like Cisco's
+		msg.Text = string(data[:length])
+		if runAppParsers(msg, config, StageHeader) {
+			parseErr = nil
+		} else {
+			msg.Text = ""
+			parseErr = parseRFC3164(msg, data, length, config)
+		}
 	}
 	if parseErr != nil {
 		return nil, parseErr
@@ -73,7 +88,33 @@ func parseSyslog(data []byte) (*Log, error) {
 	return msg, nil
 }
 
-func parseMetadata(msg *Log, data []byte) {
+func hasPayloadMarker(data []byte) bool {
+	data = trimBOM(data)
+	for _, marker := range payloadMarkers {
+		if bytes.HasPrefix(data, marker) {
+			return true
+		}
+	}
+	for _, detect := range payloadDetectors {
+		if detect(data) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseMetadata extracts the `key=value` pairs of the message text.
+func parseMetadata(msg *Log, data []byte, config *Config) {
+	if config.LegacyMetadata {
+		parseLegacyMetadata(msg, data)
+		return
+	}
+	parseLogfmt(msg, data)
+}
+
+// parseLegacyMetadata is the key-value scanner used before parseLogfmt. It
+// searches backwards and forwards from every `=` for a key and a value.
+func parseLegacyMetadata(msg *Log, data []byte) {
 	maxLen := len(data)
 	if maxLen < 3 {
 		return
@@ -88,15 +129,20 @@ func parseMetadata(msg *Log, data []byte) {
 
 					if len(val) > 0 {
 						if f := val[0]; f >= '0' && f <= '9' {
-							if i, err := ParseInt(val); err == nil {
-								msg.Metadata[cleanString(key, true)] = i
-								success = true
-							} else if f, err := ParseFloat(val); err == nil {
-								msg.Metadata[cleanString(key, true)] = f
+							if n, ok := numberValue(val); ok {
+								msg.Metadata[cleanString(key, true)] = n
 								success = true
 							}
 						}
 					}
+					switch string(val) {
+					case "true":
+						msg.Metadata[cleanString(key, true)] = true
+						success = true
+					case "false":
+						msg.Metadata[cleanString(key, true)] = false
+						success = true
+					}
 					// Not sure what it is, cast it to string, done.
 					if !success {
 						msg.Metadata[cleanString(key, true)] = cleanString(string(val), true)
@@ -187,7 +233,7 @@ func findVal(part []byte) []byte {
 	return nil
 }
 
-func parseRFC3164(msg *Log, data []byte, length int) error {
+func parseRFC3164(msg *Log, data []byte, length int, config *Config) error {
 	i := 0
 	l := length
 
@@ -205,28 +251,39 @@ func parseRFC3164(msg *Log, data []byte, length int) error {
 	}
 
 	// Expected: `hostname program[pid]:` though both are optional
-	parseHostname(msg, data, &i, &l)
-	skipChar(data, &i, &l, ' ', -1)
-	parse3164Application(msg, data, &i, &l)
+	if !hasPayloadMarker(data[i:]) {
+		parseHostname(msg, data, &i, &l)
+		skipChar(data, &i, &l, ' ', -1)
+	}
+	if !hasPayloadMarker(data[i:]) {
+		parse3164Application(msg, data, &i, &l)
 
-	// Sometimes we'll catch in hostname instead of app
-	if msg.Hostname != "" && msg.Application == "" {
-		msg.Application = msg.Hostname
-		msg.Hostname = ""
+		// Sometimes we'll catch in hostname instead of app
+		if msg.Hostname != "" && msg.Application == "" {
+			msg.Application = msg.Hostname
+			msg.Hostname = ""
+		}
 	}
 
 	valid, textData := processText(data[i:])
 	if !valid {
 		return errCorruptedData
 	}
+
+	// payloads with a marker have their own escaping, so leave it to their
+	// parser
+	if hasPayloadMarker(data[i:]) {
+		msg.Text = textData
+		return nil
+	}
 	msg.Text = cleanString(textData, false)
 
-	parseMetadata(msg, data[i:])
+	parseMetadata(msg, data[i:], config)
 
 	return nil
 }
 
-func parseRFC5424(msg *Log, data []byte, length int) error {
+func parseRFC5424(msg *Log, data []byte, length int, config *Config) error {
 	// SYSLOG-MSG: HEADER SP STRUCTURED-DATA [SP MSG]
 	// HEADER: PRI VERSION SP TIMESTAMP SP HOSTNAME SP APP-NAME SP PROCID SP MSGID
 	i := 0
@@ -254,8 +311,7 @@ func parseRFC5424(msg *Log, data []byte, length int) error {
 		return errParse
 	}
 
-	// procid
-	parseColumn(data, &i, &l)
+	setProcID(msg, string(parseColumn(data, &i, &l)))
 	if !skipSpace(data, &i, &l) {
 		return errParse
 	}
@@ -298,14 +354,20 @@ func parseRFC5424(msg *Log, data []byte, length int) error {
 	// optional space after SD
 	skipSpace(data, &i, &l)
 
-	msg.Application = parseApplication(msg.Application)
+	var procID string
+	msg.Application, procID = parseApplication(msg.Application)
+	if msg.ProcID == "" {
+		setProcID(msg, procID)
+	}
 	valid, textData := processText(data[i:])
 	if !valid {
 		return errCorruptedData
 	}
 	msg.Text = textData
 
-	parseMetadata(msg, data[i:])
+	if !hasPayloadMarker(data[i:]) {
+		parseMetadata(msg, data[i:], config)
+	}
 
 	return nil
 }
@@ -319,11 +381,28 @@ func cleanString(s string, unquote bool) string {
 	return strings.ReplaceAll(s, "\\", "")
 }
 
-func parseApplication(app string) string {
+// parseApplication splits an `app[pid]` tag into its name and PID.
+func parseApplication(app string) (string, string) {
 	if n := strings.Index(app, "["); n >= 0 {
-		return app[:n]
+		procID := app[n+1:]
+		if m := strings.IndexByte(procID, ']'); m >= 0 {
+			procID = procID[:m]
+		}
+		return app[:n], procID
+	}
+	return app, ""
+}
+
+// setProcID stores procID on msg, along with its numeric value if it has one.
+func setProcID(msg *Log, procID string) {
+	msg.ProcID = procID
+	msg.PID = 0
+	if procID == "" {
+		return
+	}
+	if pid, err := ParseUInt([]byte(procID)); err == nil && pid <= math.MaxInt64 {
+		msg.PID = int64(pid)
 	}
-	return app
 }
 
 func parseColumn(data []byte, index *int, length *int) []byte {
@@ -418,12 +497,15 @@ func parse3164Application(msg *Log, data []byte, index *int, length *int) bool {
 	app := string(data[*index:i])
 
 	// Check for PID
+	var procID string
 	if l > 0 && data[i] == '[' {
+		pidStart := i + 1
 		for l > 0 && data[i] != ' ' && data[i] != ']' && data[i] != ':' {
 			i++
 			l--
 		}
 		if l > 0 && data[i] == ']' {
+			procID = string(data[pidStart:i])
 			i++
 			l--
 		}
@@ -447,6 +529,7 @@ func parse3164Application(msg *Log, data []byte, index *int, length *int) bool {
 	}
 
 	msg.Application = app
+	setProcID(msg, procID)
 
 	*index = i
 	*length = l
@@ -532,10 +615,19 @@ func parseDate(msg *Log, dateFormat dateFormatType, data []byte, index *int, len
 			continue
 		}
 		s := timeStr[:fmtLen]
-		ts, err := time.ParseInLocation(format, s, loc)
+		// without an offset we only know the wall clock time, which gets
+		// placed into the sender's time zone once we know who sent it
+		zoneless := loc == time.Local && format != time.RFC3339
+		parseLoc := loc
+		if zoneless {
+			parseLoc = time.UTC
+		}
+		ts, err := time.ParseInLocation(format, s, parseLoc)
 		if err == nil {
-			if ts.Year() == 0 {
-				ts = ts.AddDate(time.Now().Year(), 0, 0)
+			if zoneless {
+				msg.wallClock = ts
+				msg.hasWallClock = true
+				ts = fromWallClock(ts, loc, time.Now())
 			}
 			msg.Timestamp = ts.UnixNano()
 
@@ -555,6 +647,35 @@ func parseDate(msg *Log, dateFormat dateFormatType, data []byte, index *int, len
 	return false
 }
 
+// fromWallClock places a wall clock time into loc. If it has no year, the one
+// that puts it closest to now is picked, so that messages sent just before
+// new year and received just after don't end up a year in the future.
+func fromWallClock(wall time.Time, loc *time.Location, now time.Time) time.Time {
+	at := func(year int) time.Time {
+		return time.Date(year, wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), wall.Second(), wall.Nanosecond(), loc)
+	}
+
+	if wall.Year() != 0 {
+		return at(wall.Year())
+	}
+
+	now = now.In(loc)
+	best := at(now.Year())
+	for _, year := range []int{now.Year() - 1, now.Year() + 1} {
+		if ts := at(year); absDuration(ts.Sub(now)) < absDuration(best.Sub(now)) {
+			best = ts
+		}
+	}
+	return best
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
 func parseStructuredData(data []byte, index *int, length *int) (map[string]map[string]string, error) {
 	offset := *index
 
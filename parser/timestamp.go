@@ -0,0 +1,106 @@
+package parser
+
+import (
+	"strings"
+	"time"
+)
+
+// anyApplication is the key of Config.TimestampLayouts that applies to every
+// application.
+const anyApplication = "*"
+
+var timestampLayouts = []string{
+	// fractional seconds, separated by either `.` or `,`, are accepted by
+	// all of these
+	time.RFC3339,
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	time.RFC1123Z,
+	time.RFC1123,
+	"02/Jan/2006:15:04:05 -0700",
+	time.UnixDate,
+	time.ANSIC,
+}
+
+// parseTimestamp parses a textual timestamp, trying the given layouts before
+// the built-in ones. zoneless is true if the timestamp didn't carry an offset
+// and was parsed as UTC.
+func parseTimestamp(value string, layouts []string) (ts time.Time, zoneless bool, ok bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return
+	}
+
+	if ts, ok = parseEpoch(value); ok {
+		return ts, false, true
+	}
+
+	for _, layouts := range [][]string{layouts, timestampLayouts} {
+		for _, layout := range layouts {
+			if ts, err := time.Parse(layout, value); err == nil {
+				return ts, !layoutHasZone(layout), true
+			}
+		}
+	}
+
+	return
+}
+
+func layoutHasZone(layout string) bool {
+	return strings.Contains(layout, "Z07") || strings.Contains(layout, "-07") || strings.Contains(layout, "MST")
+}
+
+// parseEpoch parses a unix timestamp, with or without fractional part. Its
+// unit is derived from its magnitude: seconds for up to 11 integer digits,
+// then milliseconds, microseconds and nanoseconds.
+func parseEpoch(value string) (time.Time, bool) {
+	intPart, fracPart, hasFrac := strings.Cut(value, ".")
+	if intPart == "" || len(intPart) > 19 || strings.Contains(fracPart, "e") || strings.Contains(fracPart, "E") {
+		return time.Time{}, false
+	}
+
+	n, err := ParseUInt([]byte(intPart))
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	var frac float64
+	if hasFrac && fracPart != "" {
+		digits, err := ParseUInt([]byte(fracPart))
+		if err != nil {
+			return time.Time{}, false
+		}
+		frac = float64(digits)
+		for range len(fracPart) {
+			frac /= 10
+		}
+	}
+
+	var unit time.Duration
+	switch {
+	case n < 1e11:
+		unit = time.Second
+	case n < 1e14:
+		unit = time.Millisecond
+	case n < 1e17:
+		unit = time.Microsecond
+	default:
+		unit = time.Nanosecond
+	}
+
+	whole := n / uint64(time.Second/unit)
+	rest := time.Duration(n%uint64(time.Second/unit))*unit + time.Duration(frac*float64(unit))
+	return time.Unix(int64(whole), int64(rest)), true
+}
+
+// layoutsFor returns the custom timestamp layouts configured for app.
+func (c *Config) layoutsFor(app string) []string {
+	if len(c.TimestampLayouts) == 0 {
+		return nil
+	}
+	if layouts, ok := c.TimestampLayouts[app]; ok {
+		return layouts
+	}
+	return c.TimestampLayouts[anyApplication]
+}
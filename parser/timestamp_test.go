@@ -0,0 +1,106 @@
+package parser
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTimestamp(t *testing.T) {
+	want := time.Date(2024, 3, 5, 14, 7, 9, 123000000, time.UTC)
+
+	var testData = map[string]time.Time{
+		"2024-03-05T14:07:09.123Z":        want,
+		"2024-03-05T15:07:09.123+01:00":   want,
+		"2024-03-05 14:07:09.123Z":        want,
+		"2024-03-05 15:07:09.123+01:00":   want,
+		"Tue, 05 Mar 2024 14:07:09 GMT":   want.Truncate(time.Second),
+		"Tue, 05 Mar 2024 15:07:09 +0100": want.Truncate(time.Second),
+		"05/Mar/2024:15:07:09 +0100":      want.Truncate(time.Second),
+		"1709647629":                      want.Truncate(time.Second),
+		"1709647629.123":                  want,
+		"1709647629123":                   want,
+		"1709647629123000":                want,
+		"1709647629123000000":             want,
+	}
+
+	for value, expected := range testData {
+		ts, zoneless, ok := parseTimestamp(value, nil)
+		if assert.True(t, ok, value) {
+			assert.Equal(t, expected, ts.UTC(), value)
+			assert.False(t, zoneless, value)
+		}
+	}
+
+	zonelessData := map[string]time.Time{
+		"2024-03-05T14:07:09.123":   want,
+		"2024-03-05 14:07:09,123":   want,
+		"2024-03-05 14:07:09.123":   want,
+		"2024-03-05 14:07:09":       want.Truncate(time.Second),
+		"Tue Mar  5 14:07:09 2024":  want.Truncate(time.Second),
+		"  2024-03-05 14:07:09.123": want,
+	}
+
+	for value, expected := range zonelessData {
+		ts, zoneless, ok := parseTimestamp(value, nil)
+		if assert.True(t, ok, value) {
+			assert.Equal(t, expected, ts, value)
+			assert.True(t, zoneless, value)
+		}
+	}
+
+	for _, value := range []string{"", "yesterday", "1709647629.12e3", "2024-13-05 14:07:09"} {
+		_, _, ok := parseTimestamp(value, nil)
+		assert.False(t, ok, value)
+	}
+
+	ts, zoneless, ok := parseTimestamp("05.03.2024 14:07:09", []string{"02.01.2006 15:04:05"})
+	if assert.True(t, ok) {
+		assert.Equal(t, want.Truncate(time.Second), ts)
+		assert.True(t, zoneless)
+	}
+}
+
+func TestParseJSONTimestamp(t *testing.T) {
+	want := time.Date(2024, 3, 5, 14, 7, 9, 0, time.UTC)
+
+	config := &Config{
+		TimeZone: "UTC",
+		TimestampLayouts: map[string][]string{
+			"legacy":       {"02.01.2006 15:04:05"},
+			anyApplication: {"2006/01/02 15h04m05s"},
+		},
+	}
+	require.NoError(t, config.compile())
+
+	cases := map[string]bool{
+		`{"msg": "hi", "timestamp": 1709647629}`:                                 true,
+		`{"msg": "hi", "timestamp": 1709647629000}`:                              true,
+		`{"msg": "hi", "timestamp": "1709647629000"}`:                            true,
+		`{"msg": "hi", "@timestamp": "2024-03-05 14:07:09"}`:                     true,
+		`{"msg": "hi", "timestamp": "05.03.2024 14:07:09", "app": "legacy"}`:     true,
+		`{"app": "legacy", "msg": "hi", "timestamp": "05.03.2024 14:07:09"}`:     true,
+		`{"msg": "hi", "timestamp": "2024/03/05 14h07m09s"}`:                     true,
+		`{"msg": "hi", "timestamp": "2024/03/05 14h07m09s", "app": "legacy"}`:    false,
+		`{"msg": "hi", "timestamp": "05.03.2024 14:07:09", "app": "other-app"}`:  false,
+		`{"msg": "hi", "timestamp": "5th of March, 2024", "app": "other-app"}`:   false,
+		`{"msg": "hi", "timestamp": "05.03.2024 14:07:09", "app": "legacy-app"}`: false,
+		// json in a syslog message goes by the application of the message
+		`<13>Mar  5 14:07:09 host legacy: {"msg": "hi", "timestamp": "05.03.2024 14:07:09"}`:    true,
+		`<13>Mar  5 14:07:09 host other-app: {"msg": "hi", "timestamp": "05.03.2024 14:07:09"}`: false,
+	}
+
+	for raw, parsed := range cases {
+		msg := parseLineWithFallback([]byte(raw), "127.0.0.1", config)
+		require.NotNil(t, msg, raw)
+		if parsed {
+			assert.Equal(t, want.UnixNano(), msg.Timestamp, raw)
+			assert.NotContains(t, msg.Metadata, "unparsed_timestamp", raw)
+		} else {
+			assert.Contains(t, msg.Metadata, "unparsed_timestamp", fmt.Sprintf("%s: %+v", raw, msg.Metadata))
+		}
+	}
+}
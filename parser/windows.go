@@ -0,0 +1,263 @@
+package parser
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// windowsFieldPrefix namespaces the Windows event fields in Log.Metadata.
+	windowsFieldPrefix = "windows."
+	// windowsEventDataPrefix namespaces the EventData of an event.
+	windowsEventDataPrefix = windowsFieldPrefix + "eventData."
+
+	snarePrefix      = "MSWinEventLog"
+	winCollectPrefix = "AgentDevice=WindowsLog"
+)
+
+var (
+	// snareFields names the columns of the Snare format, following the
+	// MSWinEventLog marker. Empty names are dropped.
+	snareFields = []string{
+		"criticality", "channel", "snareCounter", "", "eventId", "provider", "user", "sidType", "level",
+		"computer", "category", "data", "message", "md5",
+	}
+
+	// winCollectFields renames the keys of the WinCollect format. Other keys
+	// are kept as they are.
+	winCollectFields = map[string]string{
+		"AgentLogFile": "channel", "Source": "provider", "Computer": "computer", "EventID": "eventId",
+		"EventType": "eventType", "EventCategory": "category", "RecordNumber": "recordNumber",
+		"Level": "level", "Keywords": "keywords", "Task": "task", "Opcode": "opcode", "User": "user",
+		"Domain": "domain",
+	}
+
+	// nxlogFields renames the fields im_msvistalog and im_mseventlog of NXLog
+	// add to every event.
+	nxlogFields = map[string]string{
+		"EventID": "eventId", "Channel": "channel", "SourceName": "provider", "ProviderGuid": "providerGuid",
+		"Hostname": "computer", "Keywords": "keywords", "EventType": "level", "SeverityValue": "severityValue",
+		"RecordNumber": "recordNumber", "Category": "category", "Task": "task", "Opcode": "opcode",
+		"OpcodeValue": "opcodeValue", "ProcessID": "processId", "ThreadID": "threadId", "Version": "version",
+		"Domain": "domain", "AccountName": "accountName", "UserID": "userId", "AccountType": "accountType",
+		"EventReceivedTime": "receivedTime", "SourceModuleName": "sourceModuleName",
+		"SourceModuleType": "sourceModuleType", "ActivityID": "activityId", "RelatedActivityID": "relatedActivityId",
+	}
+
+	// nxlogEventData are the EventData fields of the common Windows events,
+	// which NXLog adds next to its own. Other fields are left as they are, as
+	// they may not be from NXLog at all.
+	nxlogEventData = map[string]bool{
+		"SubjectUserSid": true, "SubjectUserName": true, "SubjectDomainName": true, "SubjectLogonId": true,
+		"TargetUserSid": true, "TargetUserName": true, "TargetDomainName": true, "TargetLogonId": true,
+		"TargetLinkedLogonId": true, "TargetOutboundUserName": true, "TargetOutboundDomainName": true,
+		"TargetSid": true, "TargetServerName": true, "TargetInfo": true, "LogonType": true,
+		"LogonProcessName": true, "AuthenticationPackageName": true, "WorkstationName": true,
+		"Workstation": true, "LogonGuid": true, "TransmittedServices": true, "LmPackageName": true,
+		"KeyLength": true, "ProcessId": true, "ProcessName": true, "IpAddress": true, "IpPort": true,
+		"ImpersonationLevel": true, "RestrictedAdminMode": true, "VirtualAccount": true, "ElevatedToken": true,
+		"Status": true, "SubStatus": true, "FailureReason": true, "NewProcessId": true, "NewProcessName": true,
+		"TokenElevationType": true, "CommandLine": true, "ParentProcessName": true, "MandatoryLabel": true,
+		"PrivilegeList": true, "ServiceName": true, "ServiceSid": true, "ServiceFileName": true,
+		"ServiceType": true, "StartType": true, "AccountName": true, "TicketOptions": true,
+		"TicketEncryptionType": true, "PreAuthType": true, "CertIssuerName": true, "CertSerialNumber": true,
+		"CertThumbprint": true, "ObjectServer": true, "ObjectType": true, "ObjectName": true, "HandleId": true,
+		"AccessList": true, "AccessMask": true, "ShareName": true, "ShareLocalPath": true,
+		"RelativeTargetName": true, "CallerProcessId": true, "CallerProcessName": true, "MemberName": true,
+		"MemberSid": true, "SamAccountName": true, "DisplayName": true, "UserPrincipalName": true,
+		"TaskName": true, "TaskContent": true, "Image": true, "ParentImage": true, "ParentCommandLine": true,
+		"User": true, "Hashes": true, "SourceIp": true, "SourcePort": true, "DestinationIp": true,
+		"DestinationPort": true, "Protocol": true, "QueryName": true, "ExecutionProcessID": true,
+		"ExecutionThreadID": true,
+	}
+
+	// nxlogDropped are the NXLog fields that end up elsewhere in the message.
+	nxlogDropped = []string{"EventTime", "Severity", "Message"}
+
+	// windowsSeverities maps the Windows levels, by name and number, and the
+	// NXLog event types onto the syslog severities.
+	windowsSeverities = map[string]int64{
+		"critical": Critical, "1": Critical,
+		"error": Error, "2": Error,
+		"warning": Warning, "3": Warning,
+		"information": Info, "info": Info, "4": Info, "log always": Info, "0": Info,
+		"verbose": Debug, "5": Debug,
+		"audit_success": Info, "success audit": Info, "audit success": Info,
+		"audit_failure": Warning, "failure audit": Warning, "audit failure": Warning,
+	}
+)
+
+// parseSnare parses the MSWinEventLog format of Snare:
+//
+//	MSWinEventLog	1	Security	42	Thu Jan 18 11:07:53 2024	4624	Microsoft-Windows-Security-Auditing	...
+//
+// Columns are separated by tabs, or whatever character follows the marker.
+func parseSnare(msg *Log) bool {
+	text := strings.TrimSpace(msg.Text)
+	if !strings.HasPrefix(text, snarePrefix) || len(text) <= len(snarePrefix) {
+		return false
+	}
+	if strings.HasPrefix(text[len(snarePrefix):], "#011") {
+		// tabs escaped by the relay
+		text = strings.ReplaceAll(text, "#011", "\t")
+	}
+
+	delimiter := text[len(snarePrefix) : len(snarePrefix)+1]
+	columns := strings.Split(text[len(snarePrefix)+1:], delimiter)
+	if len(columns) < 10 {
+		return false
+	}
+
+	for i, value := range columns {
+		value = strings.TrimSpace(value)
+		if i >= len(snareFields) || snareFields[i] == "" || value == "" || value == "N/A" {
+			continue
+		}
+		msg.Metadata[windowsFieldPrefix+snareFields[i]] = intOrString(value)
+	}
+
+	if ts, err := time.Parse("Mon Jan _2 15:04:05 2006", strings.TrimSpace(columns[3])); err == nil {
+		msg.wallClock = ts
+		msg.hasWallClock = true
+		msg.Timestamp = fromWallClock(ts, time.Local, time.Now()).UnixNano()
+	}
+	if message, ok := msg.Metadata[windowsFieldPrefix+"message"].(string); ok {
+		msg.Text = message
+		delete(msg.Metadata, windowsFieldPrefix+"message")
+	}
+
+	setWindowsEvent(msg)
+	return true
+}
+
+// parseWinCollect parses the tab separated `key=value` format of IBM
+// WinCollect. The message is the last field and may contain tabs itself.
+func parseWinCollect(msg *Log) bool {
+	text := strings.TrimSpace(msg.Text)
+	if !strings.HasPrefix(text, winCollectPrefix) {
+		return false
+	}
+
+	for text != "" {
+		var pair string
+		if strings.HasPrefix(text, "Message=") {
+			pair, text = text, ""
+		} else {
+			pair, text, _ = strings.Cut(text, "\t")
+		}
+
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || key == "" || value == "" {
+			continue
+		}
+
+		switch key {
+		case "Message":
+			msg.Text = strings.TrimSpace(value)
+		case "TimeGenerated":
+			if ts, ok := parseEpoch(value); ok {
+				msg.Timestamp = ts.UnixNano()
+				msg.hasWallClock = false
+			}
+			fallthrough
+		default:
+			if name, ok := winCollectFields[key]; ok {
+				key = name
+			}
+			msg.Metadata[windowsFieldPrefix+key] = intOrString(value)
+		}
+	}
+
+	setWindowsEvent(msg)
+	return true
+}
+
+// parseNXLog maps the fields of events NXLog sends as JSON or as `key=value`
+// pairs, which are already in the metadata of msg. It reports whether the
+// metadata held an event.
+func parseNXLog(msg *Log) bool {
+	if _, ok := msg.Metadata["EventID"]; !ok {
+		return false
+	}
+	_, hasChannel := msg.Metadata["Channel"]
+	_, hasModule := msg.Metadata["SourceModuleType"]
+	if !hasChannel && !hasModule {
+		return false
+	}
+
+	// key-value events leave these to us, JSON ones have consumed them
+	if eventTime, ok := msg.Metadata["EventTime"].(string); ok {
+		if ts, zoneless, ok := parseTimestamp(eventTime, nil); ok {
+			if zoneless {
+				msg.wallClock = ts
+				msg.hasWallClock = true
+				ts = fromWallClock(ts, time.Local, time.Now())
+			}
+			msg.Timestamp = ts.UnixNano()
+		}
+	}
+	if message, ok := msg.Metadata["Message"].(string); ok {
+		msg.Text = message
+	}
+	if hostname, ok := msg.Metadata["Hostname"].(string); ok {
+		msg.Hostname = hostname
+	} else if msg.Hostname != "" {
+		msg.Metadata[windowsFieldPrefix+"computer"] = msg.Hostname
+	}
+	for _, key := range nxlogDropped {
+		delete(msg.Metadata, key)
+	}
+
+	for key, value := range msg.Metadata {
+		if name, ok := nxlogFields[key]; ok {
+			delete(msg.Metadata, key)
+			msg.Metadata[windowsFieldPrefix+name] = value
+		} else if isNXLogEventData(key) {
+			delete(msg.Metadata, key)
+			msg.Metadata[windowsEventDataPrefix+key] = value
+		}
+	}
+
+	setWindowsEvent(msg)
+	return true
+}
+
+// isNXLogEventData reports whether key is an EventData field of a Windows
+// event: a known one, or one of the numbered insertion strings of the classic
+// event log.
+func isNXLogEventData(key string) bool {
+	if nxlogEventData[key] {
+		return true
+	}
+	n, ok := strings.CutPrefix(key, "param")
+	return ok && n != "" && isDigits(n)
+}
+
+// setWindowsEvent sets the hostname, severity and application of msg from the
+// fields of the Windows event in its metadata.
+func setWindowsEvent(msg *Log) {
+	if computer, ok := msg.Metadata[windowsFieldPrefix+"computer"].(string); ok && computer != "" {
+		msg.Hostname = computer
+	}
+
+	if severity, ok := windowsSeverities[strings.ToLower(windowsString(msg.Metadata[windowsFieldPrefix+"level"]))]; ok {
+		msg.Severity = severity
+	}
+
+	provider := windowsString(msg.Metadata[windowsFieldPrefix+"provider"])
+	if provider != "" && (msg.Application == "" || msg.Application == syntheticApplication) {
+		msg.Application = provider
+	}
+}
+
+func windowsString(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	default:
+		return ""
+	}
+}
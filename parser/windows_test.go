@@ -0,0 +1,121 @@
+package parser
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSnare(t *testing.T) {
+	raw := "<13>Jan 18 11:07:53 relay MSWinEventLog\t1\tSecurity\t42\tThu Jan 18 11:07:52 2024\t4625\tMicrosoft-Windows-Security-Auditing\tN/A\tN/A\tFailure Audit\tDC01.corp.example\tLogon\t\tAn account failed to log on.\t17"
+
+	msg := ParseLineWithFallback([]byte(raw), "10.1.1.1")
+	require.NotNil(t, msg)
+	assert.Equal(t, "DC01.corp.example", msg.Hostname)
+	assert.Equal(t, "Microsoft-Windows-Security-Auditing", msg.Application)
+	assert.Equal(t, "An account failed to log on.", msg.Text)
+	assert.Equal(t, int64(Warning), msg.Severity)
+	assert.Equal(t, time.Date(2024, 1, 18, 11, 7, 52, 0, time.Local).UnixNano(), msg.Timestamp)
+	assert.Equal(t, map[string]any{
+		"windows.criticality":  int64(1),
+		"windows.channel":      "Security",
+		"windows.snareCounter": int64(42),
+		"windows.eventId":      int64(4625),
+		"windows.provider":     "Microsoft-Windows-Security-Auditing",
+		"windows.level":        "Failure Audit",
+		"windows.computer":     "DC01.corp.example",
+		"windows.category":     "Logon",
+		"windows.md5":          int64(17),
+	}, msg.Metadata)
+
+	// tabs escaped by the relay
+	msg = ParseLineWithFallback([]byte("<13>Jan 18 11:07:53 relay MSWinEventLog#0111#011System#0117#011Thu Jan 18 11:07:52 2024#0117036#011Service Control Manager#011N/A#011N/A#011Information#011WS01#011None#011#011The service entered the running state."), "10.1.1.1")
+	require.NotNil(t, msg)
+	assert.Equal(t, "WS01", msg.Hostname)
+	assert.Equal(t, int64(7036), msg.Metadata["windows.eventId"])
+	assert.Equal(t, "The service entered the running state.", msg.Text)
+}
+
+func TestParseWinCollect(t *testing.T) {
+	raw := "<13>Jan 18 11:07:53 relay AgentDevice=WindowsLog\tAgentLogFile=System\tPluginVersion=7.3.1.16\tSource=Microsoft-Windows-Kernel-Power\tComputer=WS01.corp.example\tUser=\tEventID=41\tEventType=1\tLevel=Critical\tTimeGenerated=1705572472\tMessage=The system has rebooted\twithout cleanly shutting down first."
+
+	msg := ParseLineWithFallback([]byte(raw), "10.1.1.1")
+	require.NotNil(t, msg)
+	assert.Equal(t, "WS01.corp.example", msg.Hostname)
+	assert.Equal(t, "Microsoft-Windows-Kernel-Power", msg.Application)
+	assert.Equal(t, "The system has rebooted\twithout cleanly shutting down first.", msg.Text)
+	// the severity is capped at Error
+	assert.Equal(t, int64(Error), msg.Severity)
+	assert.Equal(t, time.Unix(1705572472, 0).UnixNano(), msg.Timestamp)
+	assert.Equal(t, map[string]any{
+		"windows.AgentDevice":   "WindowsLog",
+		"windows.channel":       "System",
+		"windows.PluginVersion": "7.3.1.16",
+		"windows.provider":      "Microsoft-Windows-Kernel-Power",
+		"windows.computer":      "WS01.corp.example",
+		"windows.eventId":       int64(41),
+		"windows.eventType":     int64(1),
+		"windows.level":         "Critical",
+		"windows.TimeGenerated": int64(1705572472),
+	}, msg.Metadata)
+}
+
+func TestParseNXLog(t *testing.T) {
+	raw := `{"EventTime":"2024-01-18T11:07:52Z","Hostname":"DC01.corp.example","Keywords":-9214364837600034816,"EventType":"AUDIT_FAILURE","SeverityValue":4,"Severity":"ERROR","EventID":4625,"SourceName":"Microsoft-Windows-Security-Auditing","Channel":"Security","Message":"An account failed to log on.","TargetUserName":"bob","IpAddress":"198.51.100.7","SourceModuleType":"im_msvistalog"}`
+
+	msg := ParseLineWithFallback([]byte(raw), "10.1.1.1")
+	require.NotNil(t, msg)
+	assert.Equal(t, "DC01.corp.example", msg.Hostname)
+	assert.Equal(t, "Microsoft-Windows-Security-Auditing", msg.Application)
+	assert.Equal(t, "An account failed to log on.", msg.Text)
+	assert.Equal(t, int64(Warning), msg.Severity)
+	assert.Equal(t, time.Date(2024, 1, 18, 11, 7, 52, 0, time.UTC).UnixNano(), msg.Timestamp)
+	assert.Equal(t, map[string]any{
+		"windows.computer":                 "DC01.corp.example",
+		"windows.keywords":                 int64(-9214364837600034816),
+		"windows.level":                    "AUDIT_FAILURE",
+		"windows.severityValue":            int64(4),
+		"windows.eventId":                  int64(4625),
+		"windows.provider":                 "Microsoft-Windows-Security-Auditing",
+		"windows.channel":                  "Security",
+		"windows.sourceModuleType":         "im_msvistalog",
+		"windows.eventData.TargetUserName": "bob",
+		"windows.eventData.IpAddress":      "198.51.100.7",
+	}, msg.Metadata)
+
+	// key-value pairs, as written by xm_kvp
+	raw = `<13>Jan 18 11:07:53 relay nxlog: EventTime="2024-01-18 11:07:52" Hostname=WS01 EventType=ERROR EventID=7000 SourceName="Service Control Manager" Channel=System param1=Spooler Message="The service failed to start."`
+	msg = ParseLineWithFallback([]byte(raw), "10.1.1.1")
+	require.NotNil(t, msg)
+	assert.Equal(t, "WS01", msg.Hostname)
+	assert.Equal(t, "nxlog", msg.Application)
+	assert.Equal(t, "The service failed to start.", msg.Text)
+	assert.Equal(t, int64(Error), msg.Severity)
+	assert.Equal(t, time.Date(2024, 1, 18, 11, 7, 52, 0, time.Local).UnixNano(), msg.Timestamp)
+	assert.Equal(t, "Spooler", msg.Metadata["windows.eventData.param1"])
+	assert.Equal(t, int64(7000), msg.Metadata["windows.eventId"])
+
+	// fields that aren't NXLog's stay where they are
+	raw = `<13>Jan 18 11:07:53 relay nxlog: EventTime=yesterday EventID=7000 Channel=System SequenceID=42 param1=Spooler`
+	msg = ParseLineWithFallback([]byte(raw), "10.1.1.1")
+	require.NotNil(t, msg)
+	assert.Equal(t, map[string]any{
+		"windows.eventId":          int64(7000),
+		"windows.channel":          "System",
+		"windows.computer":         "relay",
+		"windows.eventData.param1": "Spooler",
+		"SequenceID":               int64(42),
+	}, msg.Metadata)
+
+	msg = ParseLineWithFallback([]byte(`{"EventID":4625,"Channel":"Security","timestamp":"someday","TargetUserName":"bob"}`), "10.1.1.1")
+	require.NotNil(t, msg)
+	assert.Equal(t, "someday", msg.Metadata["unparsed_timestamp"])
+	assert.Equal(t, "bob", msg.Metadata["windows.eventData.TargetUserName"])
+
+	// other messages with an EventID are left alone
+	msg = ParseLineWithFallback([]byte(`{"EventID":1,"message":"hello"}`), "10.1.1.1")
+	require.NotNil(t, msg)
+	assert.Equal(t, map[string]any{"EventID": int64(1)}, msg.Metadata)
+}
@@ -1,12 +1,23 @@
 package server
 
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/axiomhq/axiom-syslog-proxy/parser"
+)
+
 const (
 	fieldApplication = "application"
 	fieldHostname    = "hostname"
+	fieldResolved    = "resolvedHostname"
+	fieldPID         = "pid"
+	fieldProcID      = "procid"
 	fieldSeverity    = "severity"
 	fieldText        = "message"
 	fieldMetadata    = "metadata"
 	fieldRemoteAddr  = "remoteAddress"
+	fieldRaw         = "raw"
 )
 
 // Config ...
@@ -14,4 +25,25 @@ type Config struct {
 	Dataset string
 	AddrUDP string
 	AddrTCP string
+	Parser  ParserConfig
+}
+
+// ParserConfig holds the parser settings of each listener. A nil config
+// applies the parser defaults.
+type ParserConfig struct {
+	UDP *parser.Config `json:"udp,omitempty"`
+	TCP *parser.Config `json:"tcp,omitempty"`
+}
+
+// ReadParserConfig reads a ParserConfig from the JSON file at path.
+func ReadParserConfig(path string) (ParserConfig, error) {
+	var config ParserConfig
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, err
+	}
+
+	err = json.Unmarshal(data, &config)
+	return config, err
 }
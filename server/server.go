@@ -37,8 +37,12 @@ func NewServer(client *axiom.Client, config *Config) (srv *Server, err error) {
 		queue:  make([]axiom.Event, 0, maxQueueSize),
 	}
 
-	srv.tcpParser = parser.New(srv.onLogMessage)
-	srv.udpParser = parser.New(srv.onLogMessage)
+	if srv.tcpParser, err = parser.NewWithConfig(srv.onLogMessage, config.Parser.TCP); err != nil {
+		return nil, err
+	}
+	if srv.udpParser, err = parser.NewWithConfig(srv.onLogMessage, config.Parser.UDP); err != nil {
+		return nil, err
+	}
 
 	if srv.tcpCloser, err = input.StartTCP(config.AddrTCP, srv.tcpParser.WriteLine); err != nil {
 		return nil, err
@@ -76,12 +80,25 @@ func LogToEvent(log *parser.Log) axiom.Event {
 	if log.Hostname != "" {
 		ev[fieldHostname] = log.Hostname
 	}
+	if log.ResolvedHostname != "" {
+		ev[fieldResolved] = log.ResolvedHostname
+	}
+	// Numeric process IDs go into `pid`, anything else a sender puts into
+	// PROCID is kept as is in `procid`.
+	if log.PID != 0 {
+		ev[fieldPID] = log.PID
+	} else if log.ProcID != "" {
+		ev[fieldProcID] = log.ProcID
+	}
 	if log.Text != "" {
 		ev[fieldText] = log.Text
 	}
 	if log.RemoteAddr != "" {
 		ev[fieldRemoteAddr] = log.RemoteAddr
 	}
+	if log.Raw != "" {
+		ev[fieldRaw] = log.Raw
+	}
 	if len(log.Metadata) > 0 {
 		ev[fieldMetadata] = log.Metadata
 	}
@@ -124,9 +141,13 @@ func (srv *Server) Run(ctx context.Context) {
 		case <-ctx.Done():
 			srv.tcpCloser.Close()
 			srv.udpCloser.Close()
+			srv.tcpParser.Stop()
+			srv.udpParser.Stop()
 			srv.Flush()
 			return
 		case <-ticker.C:
+			srv.tcpParser.Flush()
+			srv.udpParser.Flush()
 			srv.Flush()
 		}
 	}